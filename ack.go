@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ackEmoji is the reaction responders add to acknowledge an alert.
+const ackEmoji = "✅"
+
+// ackPrompt is appended to alert embeds when ACK_PROMPT is enabled.
+const ackPrompt = "React " + ackEmoji + " to acknowledge"
+
+// unackedAlert is a delivered alert whose reactions are still being polled.
+type unackedAlert struct {
+	incident Incident
+	message  DiscordMessage
+}
+
+// ackTracker polls reactions on recent alerts using DISCORD_BOT_TOKEN. It
+// only remembers alerts in memory, so it is only useful in daemon mode.
+type ackTracker struct {
+	client  *http.Client
+	cfg     *Config
+	pending map[string]unackedAlert
+}
+
+// newAckTracker returns a tracker with nothing pending.
+func newAckTracker(client *http.Client, cfg *Config) *ackTracker {
+	return &ackTracker{client: client, cfg: cfg, pending: make(map[string]unackedAlert)}
+}
+
+// Watch starts polling reactions on a delivered alert.
+func (t *ackTracker) Watch(key string, incident Incident, msg DiscordMessage) {
+	if msg.ID == "" || msg.ChannelID == "" {
+		return
+	}
+	t.pending[key] = unackedAlert{incident: incident, message: msg}
+}
+
+// Poll checks each pending alert for a ✅ from a non-bot user, logging and
+// archiving the first acknowledgement. Alerts whose incident has left the
+// feed are no longer polled.
+func (t *ackTracker) Poll(seen map[string]bool) {
+	for key, alert := range t.pending {
+		if !seen[key] {
+			delete(t.pending, key)
+			continue
+		}
+		users, err := t.reactors(alert.message)
+		if err != nil {
+			log.Printf("Error polling reactions for %q: %s", key, err)
+			continue
+		}
+		if len(users) == 0 {
+			continue
+		}
+		delete(t.pending, key)
+		log.Printf("Alert %q acknowledged by %s", key, strings.Join(users, ", "))
+		if t.cfg.ArchiveFilename == "" {
+			continue
+		}
+		now := time.Now()
+		record := ArchiveRecord{
+			Key:       key,
+			Incident:  alert.incident,
+			MessageID: alert.message.ID,
+			ChannelID: alert.message.ChannelID,
+			AckedBy:   users,
+			AckedAt:   &now,
+			SentAt:    now,
+		}
+		if err := appendArchive(expandDateTemplate(t.cfg.ArchiveFilename, now.In(t.cfg.Timezone)), record); err != nil {
+			log.Printf("Error writing acknowledgement to archive: %s", err)
+		}
+	}
+}
+
+// reactors lists the non-bot users who reacted with ackEmoji.
+func (t *ackTracker) reactors(msg DiscordMessage) ([]string, error) {
+	reqURL := fmt.Sprintf("%s/channels/%s/messages/%s/reactions/%s",
+		strings.TrimRight(t.cfg.DiscordAPIURL, "/"), msg.ChannelID, msg.ID, url.PathEscape(ackEmoji))
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bot "+t.cfg.DiscordBotToken)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Discord API returned non-2xx status: %s", resp.Status)
+	}
+
+	var users []struct {
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+		Bot        bool   `json:"bot"`
+	}
+	if err := json.Unmarshal(body, &users); err != nil {
+		return nil, fmt.Errorf("decoding reactions: %w", err)
+	}
+	var names []string
+	for _, user := range users {
+		if user.Bot {
+			continue
+		}
+		if user.GlobalName != "" {
+			names = append(names, user.GlobalName)
+		} else {
+			names = append(names, user.Username)
+		}
+	}
+	return names, nil
+}
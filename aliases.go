@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// addressAliases maps normalized alias addresses to their canonical form.
+// ADDRESS_ALIASES names a YAML file listing each canonical address with the
+// other strings the feed uses for the same place:
+//
+//	"100 S Fayetteville St":
+//	  - "100 Fayetteville St"
+//	  - "Raleigh Municipal Building"
+//
+// Matching ignores case and spacing, as geocoding does.
+type addressAliases map[string]string
+
+// loadAddressAliases reads ADDRESS_ALIASES. An alias listed under two
+// canonical addresses is an error.
+func loadAddressAliases(filename string) (addressAliases, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var groups map[string][]string
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	aliases := addressAliases{}
+	for canonical, names := range groups {
+		for _, name := range names {
+			key := normalizeAddress(name)
+			if other, ok := aliases[key]; ok && other != canonical {
+				return nil, fmt.Errorf("%s: %q is an alias of both %q and %q", filename, name, other, canonical)
+			}
+			aliases[key] = canonical
+		}
+	}
+	return aliases, nil
+}
+
+// canonical returns the canonical form of address, or address itself when
+// it is not a known alias.
+func (a addressAliases) canonical(address string) string {
+	if canonical, ok := a[normalizeAddress(address)]; ok {
+		return canonical
+	}
+	return address
+}
+
+// withAliases wraps a key function so aliases of one address share a key.
+func withAliases(keyFor func(Incident) string, aliases addressAliases) func(Incident) string {
+	return func(incident Incident) string {
+		incident.Address = aliases.canonical(incident.Address)
+		return keyFor(incident)
+	}
+}
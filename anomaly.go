@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// anomalyState is the persisted hourly history behind the anomaly alert.
+type anomalyState struct {
+	// HourStart is the start of the hour currently being counted.
+	HourStart time.Time `json:"hour_start"`
+	// Count is the number of matched new incidents so far this hour.
+	Count int `json:"count"`
+	// History holds the counts of the most recent completed hours, oldest first.
+	History []int `json:"history"`
+	// AlertedHour is the hour an anomaly was last reported, so each hour alerts once.
+	AlertedHour time.Time `json:"alerted_hour"`
+}
+
+// loadAnomalyState reads the anomaly history, starting empty if the file does not exist.
+func loadAnomalyState(filename string) (*anomalyState, error) {
+	state := &anomalyState{}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return state, nil
+	}
+	err = json.Unmarshal(data, state)
+	return state, err
+}
+
+// saveAnomalyState writes the anomaly history back to the file.
+func saveAnomalyState(filename string, state *anomalyState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Record adds matched incidents to the current hour, rolling completed hours
+// (including empty ones) into the history window.
+func (s *anomalyState) Record(matched int, now time.Time, window int) {
+	hour := now.Truncate(time.Hour)
+	if s.HourStart.IsZero() {
+		s.HourStart = hour
+	}
+	for s.HourStart.Before(hour) {
+		s.History = append(s.History, s.Count)
+		s.Count = 0
+		s.HourStart = s.HourStart.Add(time.Hour)
+		if len(s.History) > window {
+			s.History = s.History[len(s.History)-window:]
+		}
+	}
+	s.Count += matched
+}
+
+// Baseline is the simple moving average of the completed hours.
+func (s *anomalyState) Baseline() float64 {
+	if len(s.History) == 0 {
+		return 0
+	}
+	total := 0
+	for _, count := range s.History {
+		total += count
+	}
+	return float64(total) / float64(len(s.History))
+}
+
+// checkAnomaly returns a notice when this hour's rate exceeds the baseline by
+// ANOMALY_FACTOR, or "" when nothing should be reported.
+func checkAnomaly(cfg *Config, state *anomalyState) string {
+	if len(state.History) == 0 || state.Count < cfg.AnomalyMinCount || state.AlertedHour.Equal(state.HourStart) {
+		return ""
+	}
+	baseline := state.Baseline()
+	if float64(state.Count) <= baseline*cfg.AnomalyFactor {
+		return ""
+	}
+	state.AlertedHour = state.HourStart
+	return fmt.Sprintf("⚠️ Incident volume spike: %d matched incidents this hour vs. a baseline of %.1f/hour over the last %d hours.",
+		state.Count, baseline, len(state.History))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arcgisDefaultFields maps each Incident field to the feature attribute it is
+// read from when ARCGIS_FIELDS does not say otherwise.
+var arcgisDefaultFields = map[string]string{
+	"id":           "OBJECTID",
+	"jurisdiction": "jurisdiction",
+	"problem":      "problem",
+	"address":      "address",
+	"timestamp":    "timestamp",
+	"lat":          "lat",
+	"long":         "long",
+	"media_url":    "media_url",
+	"units":        "units",
+}
+
+// arcgisResponse is the subset of a FeatureServer query response we read.
+// ArcGIS reports failures as a 200 with an error object.
+type arcgisResponse struct {
+	Features []struct {
+		Attributes map[string]json.RawMessage `json:"attributes"`
+		Geometry   *struct {
+			X flexFloat `json:"x"`
+			Y flexFloat `json:"y"`
+		} `json:"geometry"`
+	} `json:"features"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// parseArcGISFields parses ARCGIS_FIELDS, comma-separated field=attribute
+// pairs such as "problem=CALL_TYPE,address=LOCATION", over the defaults.
+func parseArcGISFields(value string) (map[string]string, error) {
+	fields := make(map[string]string, len(arcgisDefaultFields))
+	for field, attribute := range arcgisDefaultFields {
+		fields[field] = attribute
+	}
+	for _, entry := range splitList(value) {
+		field, attribute, ok := strings.Cut(entry, "=")
+		field, attribute = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(attribute)
+		if !ok || attribute == "" {
+			return nil, fmt.Errorf("invalid ARCGIS_FIELDS entry %q, want field=attribute", entry)
+		}
+		if _, known := arcgisDefaultFields[field]; !known {
+			return nil, fmt.Errorf("unknown ARCGIS_FIELDS field %q", field)
+		}
+		fields[field] = attribute
+	}
+	return fields, nil
+}
+
+// decodeArcGISIncidents converts a FeatureServer query response into
+// incidents. Point geometry (x/y, assumed WGS84 via outSR=4326) wins over
+// lat/long attributes, and epoch-millisecond dates are converted to the
+// feed's timestamp layout in UTC.
+func decodeArcGISIncidents(body []byte, fields map[string]string) ([]Incident, error) {
+	var resp arcgisResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("ArcGIS error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+
+	incidents := make([]Incident, 0, len(resp.Features))
+	for _, feature := range resp.Features {
+		attr := func(field string) json.RawMessage {
+			return feature.Attributes[fields[field]]
+		}
+		incident := Incident{
+			ID:           arcgisString(attr("id")),
+			Jurisdiction: arcgisString(attr("jurisdiction")),
+			Problem:      arcgisString(attr("problem")),
+			Address:      arcgisString(attr("address")),
+			Timestamp:    arcgisTimestamp(attr("timestamp")),
+			MediaURL:     arcgisString(attr("media_url")),
+		}
+		units, err := parseUnits(attr("units"))
+		if err != nil {
+			return nil, fmt.Errorf("feature %w", err)
+		}
+		incident.Units = units
+		if feature.Geometry != nil {
+			incident.Lat, incident.Long = float64(feature.Geometry.Y), float64(feature.Geometry.X)
+		} else {
+			var lat, long flexFloat
+			if raw := attr("lat"); raw != nil {
+				if err := lat.UnmarshalJSON(raw); err != nil {
+					return nil, fmt.Errorf("feature lat: %w", err)
+				}
+			}
+			if raw := attr("long"); raw != nil {
+				if err := long.UnmarshalJSON(raw); err != nil {
+					return nil, fmt.Errorf("feature long: %w", err)
+				}
+			}
+			incident.Lat, incident.Long = float64(lat), float64(long)
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, nil
+}
+
+// arcgisString renders a string, number or null attribute as text.
+func arcgisString(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	return string(raw)
+}
+
+// arcgisTimestamp converts an esriFieldTypeDate (epoch milliseconds) to the
+// feed's layout; string dates are passed through unchanged.
+func arcgisTimestamp(raw json.RawMessage) string {
+	s := arcgisString(raw)
+	millis, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return s
+	}
+	return time.UnixMilli(millis).UTC().Format(incidentTimeLayout)
+}
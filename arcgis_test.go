@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeArcGISIncidents(t *testing.T) {
+	defaults, err := parseArcGISFields("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := parseArcGISFields("problem=CALL_TYPE, address=LOCATION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		body    string
+		want    Incident
+		wantErr string
+	}{
+		{
+			name:   "geometry and epoch date",
+			fields: defaults,
+			body:   `{"features":[{"attributes":{"OBJECTID":42,"problem":"MVC PI","address":"100 Main St","timestamp":1758873662000,"lat":1,"long":2},"geometry":{"x":-78.64,"y":35.78}}]}`,
+			want:   Incident{ID: "42", Problem: "MVC PI", Address: "100 Main St", Timestamp: "2025-09-26 08:01:02.000", Lat: 35.78, Long: -78.64},
+		},
+		{
+			name:   "lat/long attributes without geometry",
+			fields: defaults,
+			body:   `{"features":[{"attributes":{"OBJECTID":"7","problem":"MVC","lat":"35.7","long":-78.7,"timestamp":"2025-09-26 07:01:02.000","units":"E5, M12"}}]}`,
+			want:   Incident{ID: "7", Problem: "MVC", Lat: 35.7, Long: -78.7, Timestamp: "2025-09-26 07:01:02.000", Units: []string{"E5", "M12"}},
+		},
+		{
+			name:   "custom field names",
+			fields: custom,
+			body:   `{"features":[{"attributes":{"CALL_TYPE":"MVC DAMAGE","LOCATION":" 5 Oak Ave ","problem":"ignored","jurisdiction":null}}]}`,
+			want:   Incident{Problem: "MVC DAMAGE", Address: "5 Oak Ave"},
+		},
+		{
+			name:    "error object",
+			fields:  defaults,
+			body:    `{"error":{"code":400,"message":"Invalid query parameters"}}`,
+			wantErr: "ArcGIS error 400: Invalid query parameters",
+		},
+		{
+			name:    "bad coordinate",
+			fields:  defaults,
+			body:    `{"features":[{"attributes":{"lat":"north"}}]}`,
+			wantErr: "feature lat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incidents, err := decodeArcGISIncidents([]byte(tt.body), tt.fields)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(incidents) != 1 {
+				t.Fatalf("got %d incidents, want 1", len(incidents))
+			}
+			got := incidents[0]
+			if got.ID != tt.want.ID || got.Problem != tt.want.Problem || got.Address != tt.want.Address ||
+				got.Timestamp != tt.want.Timestamp || got.Lat != tt.want.Lat || got.Long != tt.want.Long ||
+				strings.Join(got.Units, ",") != strings.Join(tt.want.Units, ",") {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArcGISFields(t *testing.T) {
+	tests := []struct {
+		value   string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{"", "problem", "problem", false},
+		{"Problem=CALL_TYPE", "problem", "CALL_TYPE", false},
+		{"problem=CALL_TYPE", "address", "address", false},
+		{"problem", "", "", true},
+		{"problem=", "", "", true},
+		{"colour=COLOR", "", "", true},
+	}
+	for _, tt := range tests {
+		fields, err := parseArcGISFields(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseArcGISFields(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && fields[tt.field] != tt.want {
+			t.Errorf("parseArcGISFields(%q)[%q] = %q, want %q", tt.value, tt.field, fields[tt.field], tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// archiveRotationLayout timestamps rotated archives so they sort by age.
+const archiveRotationLayout = "20060102T150405.000Z"
+
+// ArchiveRecord is one line of the append-only incident archive.
+type ArchiveRecord struct {
+	Key       string   `json:"key"`
+	Incident  Incident `json:"incident"`
+	MessageID string   `json:"message_id,omitempty"`
+	ChannelID string   `json:"channel_id,omitempty"`
+	// FirstSeen is when the incident was first fetched; SentAt follows any
+	// delivery retries.
+	FirstSeen time.Time `json:"first_seen"`
+	SentAt    time.Time `json:"sent_at"`
+	// AckedBy and AckedAt are set on the extra record written when
+	// responders acknowledge the alert.
+	AckedBy []string   `json:"acked_by,omitempty"`
+	AckedAt *time.Time `json:"acked_at,omitempty"`
+}
+
+// archiveMu serializes archive appends and rotation, so concurrent senders
+// never interleave lines or append to a file that is being compressed.
+var archiveMu sync.Mutex
+
+// appendArchive writes a record to the archive file as a single JSON line,
+// in one write so the line lands whole.
+func appendArchive(filename string, record ArchiveRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotateArchiveIfNeeded moves the archive to a timestamped .gz file once it
+// exceeds ARCHIVE_MAX_SIZE or its first record is older than ARCHIVE_MAX_AGE,
+// then deletes all but the newest ARCHIVE_KEEP rotated files.
+func rotateArchiveIfNeeded(cfg *Config, filename string) error {
+	if cfg.ArchiveMaxSize == 0 && cfg.ArchiveMaxAge == 0 {
+		return nil
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	due := cfg.ArchiveMaxSize > 0 && info.Size() >= cfg.ArchiveMaxSize
+	if !due && cfg.ArchiveMaxAge > 0 {
+		started, err := archiveStartedAt(filename)
+		if err != nil {
+			return err
+		}
+		due = !started.IsZero() && time.Since(started) >= cfg.ArchiveMaxAge
+	}
+	if !due {
+		return nil
+	}
+
+	rotated := fmt.Sprintf("%s.%s.gz", filename, time.Now().UTC().Format(archiveRotationLayout))
+	if err := gzipFile(filename, rotated); err != nil {
+		return fmt.Errorf("compressing %s: %w", filename, err)
+	}
+	if err := os.Remove(filename); err != nil {
+		return err
+	}
+	return pruneRotatedArchives(filename, cfg.ArchiveKeep)
+}
+
+// archiveStartedAt returns the sent_at of the archive's first record.
+func archiveStartedAt(filename string) (time.Time, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return time.Time{}, err
+	}
+	var record ArchiveRecord
+	if json.Unmarshal(line, &record) != nil {
+		return time.Time{}, nil
+	}
+	return record.SentAt, nil
+}
+
+// gzipFile writes a gzip-compressed copy of src to dst.
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// pruneRotatedArchives keeps the newest keep rotated archives; 0 keeps all.
+func pruneRotatedArchives(filename string, keep int) error {
+	if keep == 0 {
+		return nil
+	}
+	rotated, err := filepath.Glob(filename + ".*.gz")
+	if err != nil {
+		return err
+	}
+	sort.Strings(rotated)
+	for len(rotated) > keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// readArchiveSince returns the alert records sent at or after since, one per
+// incident key, oldest first. Acknowledgement records are skipped, and so are
+// rotated .gz archives, so a window reaching past the last rotation is cut
+// short.
+func readArchiveSince(cfg *Config, since time.Time) ([]ArchiveRecord, error) {
+	filenames := []string{cfg.ArchiveFilename}
+	if isDateTemplate(cfg.ArchiveFilename) {
+		var err error
+		if filenames, err = filepath.Glob(dateTemplateGlob(cfg.ArchiveFilename)); err != nil {
+			return nil, err
+		}
+	}
+
+	var records []ArchiveRecord
+	seen := map[string]bool{}
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var record ArchiveRecord
+			if json.Unmarshal(scanner.Bytes(), &record) != nil || record.AckedAt != nil {
+				continue
+			}
+			if record.SentAt.Before(since) || seen[record.Key] {
+				continue
+			}
+			seen[record.Key] = true
+			records = append(records, record)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", filename, err)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].SentAt.Before(records[j].SentAt) })
+	return records, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Embed colors, as the decimal RGB values Discord expects.
+const (
+	colorInjury  = 15158332 // Red for injuries
+	colorDamage  = 15844367 // Yellow for damage/hit & run
+	colorDefault = 3447003  // Default blue for everything else
+	colorUpdate  = 9807270  // Muted gray for update follow-ups
+)
+
+// Severity is the coarse classification used for colors.
+type Severity string
+
+const (
+	SeverityInjury Severity = "injury"
+	SeverityDamage Severity = "damage"
+	SeverityOther  Severity = "other"
+)
+
+// allSeverities lists every severity, most serious first.
+var allSeverities = []Severity{SeverityInjury, SeverityDamage, SeverityOther}
+
+// severityOf classifies an incident from its problem description, upgraded
+// to injury when a dispatched unit matches MEDIC_UNIT_PATTERNS.
+func severityOf(cfg *Config, incident Incident) Severity {
+	if medicDispatched(cfg, incident) {
+		return SeverityInjury
+	}
+	problemLower := strings.ToLower(incident.Problem)
+	if strings.Contains(problemLower, "injur") {
+		return SeverityInjury
+	} else if strings.Contains(problemLower, "damage") || strings.Contains(problemLower, "hit & run") {
+		return SeverityDamage
+	}
+	return SeverityOther
+}
+
+// medicDispatched reports whether any of the incident's units is an EMS
+// unit. Incidents without unit info never match.
+func medicDispatched(cfg *Config, incident Incident) bool {
+	for _, unit := range incident.Units {
+		for _, re := range cfg.MedicUnitPatterns {
+			if re.MatchString(unit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// severityColor returns the embed color for a severity.
+func severityColor(severity Severity) int {
+	switch severity {
+	case SeverityInjury:
+		return colorInjury
+	case SeverityDamage:
+		return colorDamage
+	default:
+		return colorDefault
+	}
+}
+
+// parseColor accepts a color as "#rrggbb", "0xrrggbb" or a decimal integer.
+func parseColor(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	base := 10
+	if strings.HasPrefix(value, "#") {
+		value, base = value[1:], 16
+	} else if strings.HasPrefix(strings.ToLower(value), "0x") {
+		value, base = value[2:], 16
+	}
+	color, err := strconv.ParseInt(value, base, 32)
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("invalid color %q", value)
+	}
+	return int(color), nil
+}
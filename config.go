@@ -0,0 +1,931 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Config holds the settings read from the environment or .env file.
+type Config struct {
+	APIURL        string
+	WebhookURL    string
+	MapsAPIKey    string
+	StateFilename string
+	// MapType is the Google Static Maps maptype: roadmap, satellite, hybrid or terrain.
+	MapType string
+	// APIMethod, APIBody and APIContentType shape the feed request for
+	// feeds that want a POSTed query instead of a plain GET.
+	APIMethod      string
+	APIBody        string
+	APIContentType string
+	// APIFormat is "flat" (a JSON array of incidents) or "arcgis" (a
+	// FeatureServer query response, read via ArcGISFields).
+	APIFormat    string
+	ArcGISFields map[string]string
+	// FieldMap renames flat feed keys to Incident fields before decoding,
+	// from FIELD_MAP, e.g. "long=lng".
+	FieldMap map[string]string
+	// StateBackend is "file" (default) or "redis".
+	StateBackend string
+	RedisURL     string
+	// StateTTL expires sent keys in either backend; 0 keeps them forever.
+	StateTTL time.Duration
+	// DedupNamespace, from DEDUP_NAMESPACE, prefixes every state key so
+	// differently configured runs can share a store; see newStateStore.
+	DedupNamespace string
+	// KeyFor derives an incident's dedup key per DEDUP_STRATEGY.
+	KeyFor func(Incident) string
+	// DedupTimestampRounding rounds incident timestamps to this step before
+	// KeyFor sees them, absorbing millisecond jitter between fetches.
+	DedupTimestampRounding time.Duration
+	// FutureTSPolicy is what happens to incidents timestamped more than
+	// FutureTSTolerance ahead: "pass" (default), "clamp" to now, or "flag".
+	FutureTSPolicy    string
+	FutureTSTolerance time.Duration
+	// AddressAliases, from ADDRESS_ALIASES, canonicalizes addresses before
+	// KeyFor and the fuzzy key see them.
+	AddressAliases addressAliases
+	// FuzzyDedupWindow, when non-zero, suppresses re-alerts for the same
+	// problem at the same address within the window.
+	FuzzyDedupWindow time.Duration
+	// SignificantFields, when set, lets a fuzzy match re-alert if any of
+	// these fields differ from the earlier alert.
+	SignificantFields []string
+	// LocationCooldown, from LOCATION_COOLDOWN_MINUTES, suppresses any alert
+	// within that long of the last one in the same coordinate bucket, whose
+	// size is LocationCooldownPrecision decimal places.
+	LocationCooldown          time.Duration
+	LocationCooldownPrecision int
+	// ExternalDedupURL, when set, is asked whether another tool already
+	// reported each alert; answers are cached for ExternalDedupCacheTTL.
+	ExternalDedupURL      string
+	ExternalDedupCacheTTL time.Duration
+	// ArchiveFilename, when set, receives one JSON line per delivered alert.
+	// It and StateFilename may contain %Y, %m and %d to partition by date.
+	ArchiveFilename string
+	// ArchiveMaxSize and ArchiveMaxAge trigger rotation to a gzipped file;
+	// ArchiveKeep rotated files are kept.
+	ArchiveMaxSize int64
+	ArchiveMaxAge  time.Duration
+	ArchiveKeep    int
+	EmbedFields    []string
+	// DiscordVerbosity and the others set how much each notifier renders.
+	DiscordVerbosity        Verbosity
+	MatrixVerbosity         Verbosity
+	SNSVerbosity            Verbosity
+	GenericWebhookVerbosity Verbosity
+
+	// WebhookRetries is how many times transient webhook failures are retried.
+	WebhookRetries      int
+	WebhookRetryBackoff time.Duration
+	// FetchRetries is how many times a one-shot run retries a rate-limited
+	// (429 or 503) feed fetch, waiting out any Retry-After.
+	FetchRetries  int
+	FooterVersion bool
+	// UpdateAlerts posts a follow-up when an alerted incident's problem changes
+	// by more than UpdateThreshold; see problemChanged.
+	UpdateAlerts        bool
+	UpdateThreshold     float64
+	UpdateStateFilename string
+	// DiscordGuildID builds message links when webhook responses omit guild_id.
+	DiscordGuildID string
+	// AckPrompt asks responders to react to acknowledge; with DiscordBotToken,
+	// the daemon polls those reactions through DiscordAPIURL.
+	AckPrompt       bool
+	DiscordBotToken string
+	DiscordAPIURL   string
+	// ColorUpdate colors the update follow-up embeds for an incident.
+	ColorUpdate int
+
+	// IncidentFilters selects the alertable incidents; see parseFilterExpr.
+	IncidentFilters filterExpr
+	// GeofencePolygon, when set, drops incidents located outside it.
+	GeofencePolygon polygon
+	// Facilities, from FACILITIES_FILE, adds the nearest hospital or fire
+	// station to injury and fire alerts.
+	Facilities []Facility
+	// MinCoordPrecision is the fewest decimals a lat/long may have before it
+	// is treated as a general area; CoarseCoordsMode says whether such
+	// incidents are skipped or sent flagged as approximate, without a map.
+	MinCoordPrecision int
+	CoarseCoordsMode  string
+	// CoordDisplayDecimals is how many decimals coordinates show in alerts.
+	CoordDisplayDecimals int
+	// CoordCRS, when set, reprojects feed coordinates from COORD_CRS to WGS84.
+	CoordCRS *crs
+	// ValidateSchema checks the raw API response before processing it.
+	ValidateSchema bool
+	Routes         []Route
+	RouteMode      string
+	// ThreadNameTemplate, when set, names a thread for each new alert: one
+	// started on the message with DISCORD_BOT_TOKEN, or else the thread_name
+	// of a forum channel webhook post.
+	ThreadNameTemplate *template.Template
+	// PingRoles are the role IDs mentioned on alerts whose severity is in
+	// PingOnSeverities, for severities without a --config entry.
+	PingRoles        []string
+	PingOnSeverities []Severity
+	// MedicUnitPatterns match EMS unit IDs; an incident with such a unit
+	// dispatched is treated as an injury whatever its problem says.
+	MedicUnitPatterns []*regexp.Regexp
+	// FallbackWebhookURL receives an alert when its destination still fails
+	// after retries.
+	FallbackWebhookURL string
+	// ConfigCacheFilename keeps the last good remote --config, and
+	// ConfigRefreshInterval, when set in daemon mode, re-reads --config.
+	ConfigCacheFilename   string
+	ConfigRefreshInterval time.Duration
+	// Policy is the per-severity destinations and mentions from --config.
+	Policy *Policy
+
+	DNSResolver  string
+	IPPreference string
+	// InsecureSkipVerify disables TLS verification for the API fetch only.
+	InsecureSkipVerify bool
+	// PreflightCheck checks DNS and connectivity to each host before the
+	// daemon's first cycle.
+	PreflightCheck bool
+	// BindAddr is the source IP for the API fetch, for allowlisted feeds.
+	BindAddr net.IP
+	// HTTPTimeout bounds a whole request, body included; the others bound
+	// its connect, TLS handshake and wait-for-headers phases. Zero means none.
+	HTTPTimeout           time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// Timezone is the display zone for incident times; JurisdictionTimezones
+	// overrides it per jurisdiction.
+	Timezone              *time.Location
+	JurisdictionTimezones map[string]*time.Location
+
+	ProblemTranslations map[string]string
+	TranslationMode     string
+	// ProblemDelimiter, when set, splits problems such as "MVC / INJURY /
+	// BLOCKING" into Incident.Type and Incident.Modifiers.
+	ProblemDelimiter string
+	// RedactAddressFor lists problem patterns whose street number is hidden in alerts.
+	RedactAddressFor []string
+	// NoMapFor lists problem patterns that get no map thumbnail or map link.
+	NoMapFor []string
+	// MapForJurisdictions, when set, limits map thumbnails to incidents in
+	// these jurisdictions, to save Static Maps quota.
+	MapForJurisdictions []string
+
+	// PollInterval enables daemon mode when non-zero.
+	PollInterval time.Duration
+	// PollCron, when set, enables daemon mode and schedules each cycle from
+	// POLL_CRON instead of a fixed interval.
+	PollCron *cronSchedule
+	// ExitCodeOnNoNew is the one-shot exit code for a successful run that
+	// sent nothing, so scripts can tell quiet from broken (which exits 1).
+	ExitCodeOnNoNew int
+	// AdaptivePoll backs the interval off between PollMinInterval and
+	// PollMaxInterval while the feed is quiet or failing.
+	AdaptivePoll    bool
+	PollMinInterval time.Duration
+	PollMaxInterval time.Duration
+	StatsWebhookURL string
+	StatsInterval   time.Duration
+	// StatsFilename, when set, keeps a per-day count of matching incidents
+	// by problem type for --report.
+	StatsFilename string
+	// DigestAt is the daily "15:04" time, in TIMEZONE, at which a daemon
+	// posts a digest of the last DigestWindow of ARCHIVE_FILE. DigestMode
+	// lists incidents or aggregates counts per problem, DigestSort orders
+	// them by count, time or severity, and DigestLimit caps the rows.
+	DigestAt         string
+	DigestWebhookURL string
+	DigestWindow     time.Duration
+	DigestMode       string
+	DigestSort       string
+	DigestLimit      int
+	// WebhookHealthInterval, when set in daemon mode, checks every Discord
+	// webhook this often and reports changes to OpsWebhookURL.
+	WebhookHealthInterval time.Duration
+	OpsWebhookURL         string
+	// ShutdownTimeout bounds how long a daemon waits for in-flight sends and
+	// the state save after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration
+	// MetricsAddr, when set in daemon mode, serves /metrics and a dashboard.
+	MetricsAddr string
+	// PushgatewayURL, when set, receives the same metrics after every run or
+	// cycle, grouped under PushgatewayJob.
+	PushgatewayURL string
+	PushgatewayJob string
+	// SnapshotFilename receives the matching incidents after each fetch, for
+	// a separate --dashboard process.
+	SnapshotFilename string
+	// StateEndpoint serves active incidents and archived alerts as JSON at
+	// /state on the metrics server, behind HTTP_BASIC_USER and HTTP_BASIC_PASS.
+	StateEndpoint bool
+	HTTPBasicUser string
+	HTTPBasicPass string
+
+	// OverviewWebhookURL enables a single, repeatedly edited active-incident summary.
+	OverviewWebhookURL    string
+	OverviewStateFilename string
+
+	// GeocoderURL enables forward geocoding of incidents without coordinates.
+	GeocoderURL     string
+	GeocodeSuffix   string
+	GeocodeInterval time.Duration
+
+	// GenericWebhookURL adds a notifier that POSTs GenericWebhookTemplate.
+	GenericWebhookURL      string
+	GenericWebhookTemplate string
+	GenericWebhookHeaders  string
+
+	// WeatherAPIURL enables a current-conditions field on each alert.
+	WeatherAPIURL string
+	WeatherAPIKey string
+	// RoutingAPIURL, an OSRM-compatible server, and RoutingStation enable a
+	// drive-time field from the station on each alert.
+	RoutingAPIURL  string
+	RoutingStation LatLng
+
+	// MatrixHomeserver, MatrixToken and MatrixRoomID add a Matrix notifier.
+	MatrixHomeserver string
+	MatrixToken      string
+	MatrixRoomID     string
+	// MQTTBroker adds an MQTT notifier publishing alerts as JSON to MQTTTopic.
+	MQTTBroker   string
+	MQTTTopic    string
+	MQTTClientID string
+	MQTTUsername string
+	MQTTPassword string
+	MQTTQoS      byte
+	MQTTRetain   bool
+	// SNSTopicARN adds an AWS SNS notifier; SNSMessageFormat is text or json.
+	SNSTopicARN      string
+	SNSMessageFormat string
+
+	// TTSURL enables an audio readout link on each alert.
+	TTSURL string
+
+	// AnomalyFactor enables volume-spike notices when greater than zero.
+	AnomalyFactor        float64
+	AnomalyMinCount      int
+	AnomalyBaselineHours int
+	AnomalyWebhookURL    string
+	AnomalyStateFilename string
+}
+
+// defaultEmbedFields is the field layout used when EMBED_FIELDS is unset.
+const defaultEmbedFields = "address,jurisdiction"
+
+// defaultMedicUnitPatterns matches common EMS unit IDs such as "M12",
+// "MEDIC 4" and "EMS-3" when MEDIC_UNIT_PATTERNS is unset.
+const defaultMedicUnitPatterns = `^(M|MED|MEDIC|EMS|AMB)[ -]?\d+$`
+
+// loadConfig reads the settings from the environment and validates them.
+func loadConfig() (*Config, error) {
+	cfg := &Config{
+		APIURL:          os.Getenv("RWECC_URL"),
+		WebhookURL:      os.Getenv("RWECC_DISCORD_HOOK"),
+		MapsAPIKey:      os.Getenv("GOOGLE_MAPS_API_KEY"),
+		StateFilename:   envOrDefault("STATE_FILE", "sent_rwecc_incidents.json"),
+		ArchiveFilename: os.Getenv("ARCHIVE_FILE"),
+	}
+
+	if cfg.APIURL == "" || cfg.WebhookURL == "" {
+		return nil, errors.New("RWECC_URL and RWECC_DISCORD_HOOK must be set in your environment or .env file")
+	}
+
+	var err error
+	cfg.MapType = strings.ToLower(envOrDefault("MAP_TYPE", "roadmap"))
+	if !slices.Contains(mapTypes, cfg.MapType) {
+		return nil, fmt.Errorf("MAP_TYPE must be one of %s, got %q", strings.Join(mapTypes, ", "), cfg.MapType)
+	}
+	if cfg.ArchiveMaxSize, err = envSize("ARCHIVE_MAX_SIZE"); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveMaxAge, err = envDuration("ARCHIVE_MAX_AGE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveKeep, err = envInt("ARCHIVE_KEEP", 5); err != nil {
+		return nil, err
+	}
+	cfg.EmbedFields = parseEmbedFields(envOrDefault("EMBED_FIELDS", defaultEmbedFields))
+	if cfg.DiscordVerbosity, err = envVerbosity("DISCORD_VERBOSITY"); err != nil {
+		return nil, err
+	}
+	if cfg.MatrixVerbosity, err = envVerbosity("MATRIX_VERBOSITY"); err != nil {
+		return nil, err
+	}
+	if cfg.SNSVerbosity, err = envVerbosity("SNS_VERBOSITY"); err != nil {
+		return nil, err
+	}
+	if cfg.GenericWebhookVerbosity, err = envVerbosity("GENERIC_WEBHOOK_VERBOSITY"); err != nil {
+		return nil, err
+	}
+
+	cfg.StateBackend = strings.ToLower(envOrDefault("STATE_BACKEND", "file"))
+	cfg.RedisURL = os.Getenv("REDIS_URL")
+	if cfg.StateBackend == "redis" && cfg.RedisURL == "" {
+		return nil, errors.New("REDIS_URL must be set when STATE_BACKEND=redis")
+	}
+	if cfg.StateTTL, err = envDuration("STATE_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.DedupNamespace = os.Getenv("DEDUP_NAMESPACE")
+	if !dedupNamespaceRe.MatchString(cfg.DedupNamespace) {
+		return nil, fmt.Errorf("DEDUP_NAMESPACE %q may only contain letters, digits, '.', '_' and '-'", cfg.DedupNamespace)
+	}
+
+	strategy := strings.ToLower(envOrDefault("DEDUP_STRATEGY", dedupTimestampAddress))
+	if cfg.KeyFor, err = newKeyFunc(strategy, os.Getenv("DEDUP_TEMPLATE")); err != nil {
+		return nil, err
+	}
+	if cfg.DedupTimestampRounding, err = envDuration("DEDUP_TIMESTAMP_ROUNDING", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DedupTimestampRounding < 0 {
+		return nil, errors.New("DEDUP_TIMESTAMP_ROUNDING must not be negative")
+	}
+	if cfg.DedupTimestampRounding > 0 {
+		cfg.KeyFor = withTimestampRounding(cfg.KeyFor, cfg.DedupTimestampRounding)
+	}
+	cfg.FutureTSPolicy = strings.ToLower(envOrDefault("FUTURE_TS_POLICY", "pass"))
+	if cfg.FutureTSPolicy != "pass" && cfg.FutureTSPolicy != "clamp" && cfg.FutureTSPolicy != "flag" {
+		return nil, fmt.Errorf("FUTURE_TS_POLICY must be clamp, flag or pass, got %q", cfg.FutureTSPolicy)
+	}
+	if cfg.FutureTSTolerance, err = envDuration("FUTURE_TS_TOLERANCE", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if filename := os.Getenv("ADDRESS_ALIASES"); filename != "" {
+		if cfg.AddressAliases, err = loadAddressAliases(filename); err != nil {
+			return nil, fmt.Errorf("ADDRESS_ALIASES: %w", err)
+		}
+		cfg.KeyFor = withAliases(cfg.KeyFor, cfg.AddressAliases)
+	}
+
+	if cfg.WebhookRetries, err = envInt("WEBHOOK_RETRIES", 3); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookRetryBackoff, err = envDuration("WEBHOOK_RETRY_BACKOFF", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.FetchRetries, err = envInt("FETCH_RETRIES", 3); err != nil {
+		return nil, err
+	}
+
+	if cfg.FuzzyDedupWindow, err = envDuration("FUZZY_DEDUP_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	for _, field := range splitList(os.Getenv("SIGNIFICANT_FIELDS")) {
+		field = strings.ToLower(field)
+		if field == "problem" || field == "address" {
+			return nil, fmt.Errorf("SIGNIFICANT_FIELDS: %q is part of the fuzzy key, so a change to it already re-alerts", field)
+		}
+		if _, ok := significantFields[field]; !ok {
+			return nil, fmt.Errorf("SIGNIFICANT_FIELDS: unknown field %q", field)
+		}
+		cfg.SignificantFields = append(cfg.SignificantFields, field)
+	}
+	if len(cfg.SignificantFields) > 0 && cfg.FuzzyDedupWindow == 0 {
+		log.Println("Warning: SIGNIFICANT_FIELDS is only used with FUZZY_DEDUP_WINDOW")
+	}
+	cooldownMinutes, err := envInt("LOCATION_COOLDOWN_MINUTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.LocationCooldown = time.Duration(cooldownMinutes) * time.Minute
+	if cfg.LocationCooldownPrecision, err = envInt("LOCATION_COOLDOWN_PRECISION", 3); err != nil {
+		return nil, err
+	}
+	if cfg.LocationCooldownPrecision > 6 {
+		return nil, fmt.Errorf("LOCATION_COOLDOWN_PRECISION must be 0 to 6 decimal places, got %d", cfg.LocationCooldownPrecision)
+	}
+	cfg.ExternalDedupURL = os.Getenv("EXTERNAL_DEDUP_URL")
+	if cfg.ExternalDedupURL != "" {
+		if err := validHTTPURL(cfg.ExternalDedupURL); err != nil {
+			return nil, fmt.Errorf("EXTERNAL_DEDUP_URL: %w", err)
+		}
+	}
+	if cfg.ExternalDedupCacheTTL, err = envDuration("EXTERNAL_DEDUP_CACHE_TTL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	cfg.FooterVersion = os.Getenv("FOOTER_VERSION") == "true"
+	cfg.UpdateAlerts = os.Getenv("UPDATE_ALERTS") == "true"
+	if cfg.UpdateThreshold, err = envFloat("UPDATE_DISTANCE_THRESHOLD", 0.3); err != nil {
+		return nil, err
+	}
+	if cfg.UpdateThreshold > 1 {
+		return nil, errors.New("UPDATE_DISTANCE_THRESHOLD must be between 0 and 1")
+	}
+	cfg.UpdateStateFilename = envOrDefault("UPDATE_STATE_FILE", "update_state.json")
+	cfg.DiscordGuildID = os.Getenv("DISCORD_GUILD_ID")
+	cfg.AckPrompt = os.Getenv("ACK_PROMPT") == "true"
+	cfg.DiscordBotToken = os.Getenv("DISCORD_BOT_TOKEN")
+	cfg.DiscordAPIURL = envOrDefault("DISCORD_API_URL", "https://discord.com/api/v10")
+	if cfg.ColorUpdate, err = envColor("COLOR_UPDATE", colorUpdate); err != nil {
+		return nil, err
+	}
+
+	cfg.APIMethod = strings.ToUpper(envOrDefault("API_METHOD", http.MethodGet))
+	if cfg.APIMethod != http.MethodGet && cfg.APIMethod != http.MethodPost {
+		return nil, fmt.Errorf("API_METHOD must be GET or POST, got %q", cfg.APIMethod)
+	}
+	cfg.APIBody = os.Getenv("API_BODY")
+	cfg.APIContentType = envOrDefault("API_CONTENT_TYPE", "application/json")
+
+	cfg.APIFormat = strings.ToLower(envOrDefault("API_FORMAT", "flat"))
+	switch cfg.APIFormat {
+	case "flat":
+		if cfg.FieldMap, err = parseFieldMap(os.Getenv("FIELD_MAP")); err != nil {
+			return nil, err
+		}
+	case "arcgis":
+		if os.Getenv("FIELD_MAP") != "" {
+			return nil, errors.New("FIELD_MAP only applies to API_FORMAT=flat; use ARCGIS_FIELDS")
+		}
+		if cfg.ArcGISFields, err = parseArcGISFields(os.Getenv("ARCGIS_FIELDS")); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("API_FORMAT must be flat or arcgis, got %q", cfg.APIFormat)
+	}
+
+	cfg.ValidateSchema = os.Getenv("VALIDATE_SCHEMA") == "true"
+	if cfg.ValidateSchema && cfg.APIFormat != "flat" {
+		return nil, errors.New("VALIDATE_SCHEMA only supports API_FORMAT=flat")
+	}
+	if cfg.IncidentFilters, err = parseFilterExpr(envOrDefault("INCIDENT_FILTERS", "MVC")); err != nil {
+		return nil, err
+	}
+	if len(cfg.IncidentFilters) == 0 {
+		return nil, errors.New("INCIDENT_FILTERS must not be empty")
+	}
+	if value := os.Getenv("GEOFENCE_POLYGON"); value != "" {
+		if cfg.GeofencePolygon, err = parsePolygon(value); err != nil {
+			return nil, fmt.Errorf("GEOFENCE_POLYGON: %w", err)
+		}
+	}
+	if filename := os.Getenv("FACILITIES_FILE"); filename != "" {
+		if cfg.Facilities, err = loadFacilities(filename); err != nil {
+			return nil, fmt.Errorf("FACILITIES_FILE: %w", err)
+		}
+	}
+	if cfg.MinCoordPrecision, err = envInt("MIN_COORD_PRECISION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MinCoordPrecision < 0 {
+		return nil, errors.New("MIN_COORD_PRECISION must not be negative")
+	}
+	if value := os.Getenv("COORD_CRS"); value != "" {
+		if cfg.CoordCRS, err = parseCRS(value); err != nil {
+			return nil, fmt.Errorf("COORD_CRS: %w", err)
+		}
+	}
+	if cfg.CoordDisplayDecimals, err = envInt("COORD_DISPLAY_DECIMALS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.CoordDisplayDecimals < 0 || cfg.CoordDisplayDecimals > 15 {
+		return nil, errors.New("COORD_DISPLAY_DECIMALS must be between 0 and 15")
+	}
+	cfg.CoarseCoordsMode = strings.ToLower(envOrDefault("COARSE_COORDS_MODE", "skip"))
+	if cfg.CoarseCoordsMode != "skip" && cfg.CoarseCoordsMode != "flag" {
+		return nil, fmt.Errorf("COARSE_COORDS_MODE must be skip or flag, got %q", cfg.CoarseCoordsMode)
+	}
+	if cfg.Routes, err = parseRoutes(os.Getenv("ROUTES")); err != nil {
+		return nil, err
+	}
+	cfg.RouteMode = strings.ToLower(envOrDefault("ROUTE_MODE", "first"))
+	if cfg.RouteMode != "first" && cfg.RouteMode != "all" {
+		return nil, fmt.Errorf("ROUTE_MODE must be first or all, got %q", cfg.RouteMode)
+	}
+
+	if resolver := os.Getenv("DNS_RESOLVER"); resolver != "" {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			resolver = net.JoinHostPort(resolver, "53")
+		}
+		cfg.DNSResolver = resolver
+	}
+
+	cfg.InsecureSkipVerify = os.Getenv("INSECURE_SKIP_VERIFY") == "true"
+	if cfg.InsecureSkipVerify {
+		log.Println("WARNING: INSECURE_SKIP_VERIFY is enabled; the API feed's TLS certificate will NOT be verified. Never use this in production.")
+	}
+
+	cfg.IPPreference = strings.ToLower(os.Getenv("IP_PREFERENCE"))
+	if cfg.IPPreference != "" && cfg.IPPreference != "ipv4" && cfg.IPPreference != "ipv6" {
+		return nil, fmt.Errorf("IP_PREFERENCE must be ipv4 or ipv6, got %q", cfg.IPPreference)
+	}
+
+	cfg.PreflightCheck = os.Getenv("PREFLIGHT_CHECK") == "true"
+	if value := os.Getenv("BIND_ADDR"); value != "" {
+		if cfg.BindAddr, err = localIP(value); err != nil {
+			return nil, fmt.Errorf("BIND_ADDR: %w", err)
+		}
+	}
+
+	timeoutSeconds, err := envInt("HTTP_TIMEOUT_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.HTTPTimeout = time.Duration(timeoutSeconds) * time.Second
+	if cfg.DialTimeout, err = envDuration("DIAL_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.TLSHandshakeTimeout, err = envDuration("TLS_HANDSHAKE_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ResponseHeaderTimeout, err = envDuration("RESPONSE_HEADER_TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+
+	if cfg.Timezone, err = time.LoadLocation(envOrDefault("TIMEZONE", "America/New_York")); err != nil {
+		return nil, fmt.Errorf("TIMEZONE: %w", err)
+	}
+	if cfg.JurisdictionTimezones, err = parseJurisdictionTimezones(os.Getenv("JURISDICTION_TIMEZONES")); err != nil {
+		return nil, err
+	}
+
+	if filename := os.Getenv("PROBLEM_TRANSLATIONS"); filename != "" {
+		translations, err := loadProblemTranslations(filename)
+		if err != nil {
+			return nil, fmt.Errorf("loading PROBLEM_TRANSLATIONS: %w", err)
+		}
+		cfg.ProblemTranslations = translations
+	}
+	cfg.TranslationMode = strings.ToLower(envOrDefault("PROBLEM_TRANSLATION_MODE", "replace"))
+	if cfg.TranslationMode != "replace" && cfg.TranslationMode != "augment" {
+		return nil, fmt.Errorf("PROBLEM_TRANSLATION_MODE must be replace or augment, got %q", cfg.TranslationMode)
+	}
+	cfg.ProblemDelimiter = os.Getenv("PROBLEM_DELIMITER")
+	cfg.RedactAddressFor = splitList(os.Getenv("REDACT_ADDRESS_FOR"))
+	cfg.NoMapFor = splitList(os.Getenv("NO_MAP_FOR"))
+	cfg.MapForJurisdictions = splitList(os.Getenv("MAP_FOR_JURISDICTIONS"))
+	if len(cfg.MapForJurisdictions) > 0 && cfg.MapsAPIKey == "" {
+		log.Println("Warning: MAP_FOR_JURISDICTIONS has no effect without GOOGLE_MAPS_API_KEY")
+	}
+
+	if cfg.PollInterval, err = envDuration("POLL_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("POLL_CRON"); value != "" {
+		if cfg.PollInterval > 0 {
+			return nil, errors.New("set either POLL_INTERVAL or POLL_CRON, not both")
+		}
+		if cfg.PollCron, err = parseCron(value, cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("POLL_CRON: %w", err)
+		}
+		if cfg.PollCron.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("POLL_CRON %q never matches", value)
+		}
+	}
+	daemon := daemonMode(cfg)
+	if cfg.ExitCodeOnNoNew, err = envInt("EXIT_CODE_ON_NO_NEW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExitCodeOnNoNew == 1 || cfg.ExitCodeOnNoNew > 125 {
+		return nil, errors.New("EXIT_CODE_ON_NO_NEW must be 0 or between 2 and 125")
+	}
+	cfg.AdaptivePoll = os.Getenv("ADAPTIVE_POLL") == "true"
+	if cfg.PollMinInterval, err = envDuration("POLL_MIN_INTERVAL", cfg.PollInterval); err != nil {
+		return nil, err
+	}
+	if cfg.PollMaxInterval, err = envDuration("POLL_MAX_INTERVAL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.AdaptivePoll && cfg.PollCron != nil {
+		return nil, errors.New("ADAPTIVE_POLL cannot be combined with POLL_CRON")
+	}
+	if cfg.AdaptivePoll && cfg.PollInterval > 0 {
+		if cfg.PollMinInterval <= 0 || cfg.PollMaxInterval < cfg.PollMinInterval {
+			return nil, errors.New("ADAPTIVE_POLL needs 0 < POLL_MIN_INTERVAL <= POLL_MAX_INTERVAL")
+		}
+	}
+	cfg.StatsWebhookURL = os.Getenv("STATS_WEBHOOK")
+	cfg.StatsFilename = os.Getenv("STATS_FILE")
+	if cfg.StatsInterval, err = envDuration("STATS_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.StatsWebhookURL != "" && !daemon {
+		log.Println("Warning: STATS_WEBHOOK is only used in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+	if cfg.DiscordBotToken != "" && !daemon {
+		log.Println("Warning: acknowledgement polling with DISCORD_BOT_TOKEN is only done in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+	cfg.ConfigCacheFilename = envOrDefault("CONFIG_CACHE_FILE", "config_cache.yaml")
+	if cfg.ConfigRefreshInterval, err = envDuration("CONFIG_REFRESH_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ConfigRefreshInterval > 0 && !daemon {
+		log.Println("Warning: CONFIG_REFRESH_INTERVAL is only used in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+	if cfg.ShutdownTimeout, err = envDuration("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if os.Getenv("SHUTDOWN_TIMEOUT") != "" && !daemon {
+		log.Println("Warning: SHUTDOWN_TIMEOUT is only used in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+	cfg.MetricsAddr = os.Getenv("METRICS_ADDR")
+	cfg.PushgatewayURL = os.Getenv("PUSHGATEWAY_URL")
+	if cfg.PushgatewayURL != "" {
+		if err := validHTTPURL(cfg.PushgatewayURL); err != nil {
+			return nil, fmt.Errorf("PUSHGATEWAY_URL: %w", err)
+		}
+	}
+	cfg.PushgatewayJob = envOrDefault("PUSHGATEWAY_JOB", "911-reporting")
+	cfg.SnapshotFilename = os.Getenv("SNAPSHOT_FILE")
+	cfg.StateEndpoint = os.Getenv("STATE_ENDPOINT") == "true"
+	cfg.HTTPBasicUser = os.Getenv("HTTP_BASIC_USER")
+	cfg.HTTPBasicPass = os.Getenv("HTTP_BASIC_PASS")
+	if cfg.StateEndpoint {
+		if cfg.HTTPBasicUser == "" || cfg.HTTPBasicPass == "" {
+			return nil, errors.New("STATE_ENDPOINT needs HTTP_BASIC_USER and HTTP_BASIC_PASS, since it exposes incident data")
+		}
+		if cfg.ArchiveFilename == "" {
+			return nil, errors.New("STATE_ENDPOINT needs ARCHIVE_FILE, which recent alerts are read from")
+		}
+	}
+	cfg.DigestAt = os.Getenv("DIGEST_AT")
+	if cfg.DigestAt != "" {
+		if _, err := time.Parse("15:04", cfg.DigestAt); err != nil {
+			return nil, fmt.Errorf("DIGEST_AT must be a time of day like 08:00, got %q", cfg.DigestAt)
+		}
+		if cfg.ArchiveFilename == "" {
+			return nil, errors.New("DIGEST_AT needs ARCHIVE_FILE, which the digest is built from")
+		}
+		if !daemon {
+			log.Println("Warning: DIGEST_AT is only used in daemon mode (POLL_INTERVAL or POLL_CRON); use --digest from cron instead")
+		}
+	}
+	cfg.DigestWebhookURL = envOrDefault("DIGEST_WEBHOOK", cfg.WebhookURL)
+	if cfg.DigestWindow, err = envDuration("DIGEST_WINDOW", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.DigestMode = strings.ToLower(envOrDefault("DIGEST_MODE", "list"))
+	if cfg.DigestMode != "list" && cfg.DigestMode != "counts" {
+		return nil, fmt.Errorf("DIGEST_MODE must be list or counts, got %q", cfg.DigestMode)
+	}
+	defaultSort := "time"
+	if cfg.DigestMode == "counts" {
+		defaultSort = "count"
+	}
+	cfg.DigestSort = strings.ToLower(envOrDefault("DIGEST_SORT", defaultSort))
+	if cfg.DigestSort != "count" && cfg.DigestSort != "time" && cfg.DigestSort != "severity" {
+		return nil, fmt.Errorf("DIGEST_SORT must be count, time or severity, got %q", cfg.DigestSort)
+	}
+	if cfg.DigestLimit, err = envInt("DIGEST_LIMIT", 10); err != nil {
+		return nil, err
+	}
+	if cfg.DigestLimit < 1 {
+		return nil, errors.New("DIGEST_LIMIT must be at least 1")
+	}
+
+	if cfg.WebhookHealthInterval, err = envDuration("WEBHOOK_HEALTHCHECK_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	cfg.OpsWebhookURL = os.Getenv("OPS_WEBHOOK")
+	if cfg.WebhookHealthInterval > 0 && !daemon {
+		log.Println("Warning: WEBHOOK_HEALTHCHECK_INTERVAL is only used in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+
+	if cfg.PreflightCheck && !daemon {
+		log.Println("Warning: PREFLIGHT_CHECK is only done in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+	if cfg.MetricsAddr != "" && !daemon {
+		log.Println("Warning: METRICS_ADDR is only used in daemon mode (POLL_INTERVAL or POLL_CRON)")
+	}
+
+	if cfg.AnomalyFactor, err = envFloat("ANOMALY_FACTOR", 0); err != nil {
+		return nil, err
+	}
+	if cfg.AnomalyMinCount, err = envInt("ANOMALY_MIN_COUNT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.AnomalyBaselineHours, err = envInt("ANOMALY_BASELINE_HOURS", 24); err != nil {
+		return nil, err
+	}
+	if cfg.AnomalyBaselineHours < 1 {
+		return nil, errors.New("ANOMALY_BASELINE_HOURS must be at least 1")
+	}
+	if value := os.Getenv("THREAD_NAME_TEMPLATE"); value != "" {
+		if cfg.ThreadNameTemplate, err = parseThreadNameTemplate(value); err != nil {
+			return nil, fmt.Errorf("THREAD_NAME_TEMPLATE: %w", err)
+		}
+	}
+	cfg.PingRoles = splitList(os.Getenv("PING_ROLES"))
+	for _, role := range cfg.PingRoles {
+		if !validRoleID(role) {
+			return nil, fmt.Errorf("PING_ROLES: %q is not a role ID", role)
+		}
+	}
+	for _, name := range splitList(envOrDefault("PING_ON_SEVERITIES", "injury")) {
+		severity := Severity(strings.ToLower(name))
+		if !slices.Contains(allSeverities, severity) {
+			return nil, fmt.Errorf("PING_ON_SEVERITIES: unknown severity %q, want injury, damage or other", name)
+		}
+		cfg.PingOnSeverities = append(cfg.PingOnSeverities, severity)
+	}
+	for _, pattern := range splitList(envOrDefault("MEDIC_UNIT_PATTERNS", defaultMedicUnitPatterns)) {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("MEDIC_UNIT_PATTERNS: %w", err)
+		}
+		cfg.MedicUnitPatterns = append(cfg.MedicUnitPatterns, re)
+	}
+	cfg.FallbackWebhookURL = os.Getenv("FALLBACK_WEBHOOK_URL")
+	cfg.AnomalyWebhookURL = envOrDefault("ANOMALY_WEBHOOK", cfg.WebhookURL)
+	cfg.AnomalyStateFilename = envOrDefault("ANOMALY_STATE_FILE", "anomaly_state.json")
+
+	cfg.OverviewWebhookURL = os.Getenv("OVERVIEW_WEBHOOK")
+	cfg.OverviewStateFilename = envOrDefault("OVERVIEW_STATE_FILE", "overview_state.json")
+
+	cfg.GenericWebhookURL = os.Getenv("GENERIC_WEBHOOK_URL")
+	cfg.GenericWebhookTemplate = envOrDefault("GENERIC_WEBHOOK_TEMPLATE", defaultGenericTemplate)
+	cfg.GenericWebhookHeaders = os.Getenv("GENERIC_WEBHOOK_HEADERS")
+
+	cfg.MatrixHomeserver = os.Getenv("MATRIX_HOMESERVER")
+	cfg.MatrixToken = os.Getenv("MATRIX_TOKEN")
+	cfg.MatrixRoomID = os.Getenv("MATRIX_ROOM_ID")
+	if cfg.MatrixHomeserver != "" && (cfg.MatrixToken == "" || cfg.MatrixRoomID == "") {
+		return nil, errors.New("MATRIX_HOMESERVER needs MATRIX_TOKEN and MATRIX_ROOM_ID")
+	}
+
+	cfg.MQTTBroker = os.Getenv("MQTT_BROKER")
+	cfg.MQTTTopic = envOrDefault("MQTT_TOPIC", "911-reporting/incidents")
+	cfg.MQTTClientID = envOrDefault("MQTT_CLIENT_ID", "911-reporting")
+	cfg.MQTTUsername = os.Getenv("MQTT_USERNAME")
+	cfg.MQTTPassword = os.Getenv("MQTT_PASSWORD")
+	cfg.MQTTRetain = os.Getenv("MQTT_RETAIN") == "true"
+	if cfg.MQTTBroker != "" {
+		if err := validMQTTBroker(cfg.MQTTBroker); err != nil {
+			return nil, fmt.Errorf("MQTT_BROKER: %w", err)
+		}
+	}
+	qos, err := envInt("MQTT_QOS", 1)
+	if err != nil {
+		return nil, err
+	}
+	if qos < 0 || qos > 2 {
+		return nil, errors.New("MQTT_QOS must be 0, 1 or 2")
+	}
+	cfg.MQTTQoS = byte(qos)
+
+	cfg.SNSTopicARN = os.Getenv("SNS_TOPIC_ARN")
+	if cfg.SNSTopicARN != "" && !validSNSTopicARN(cfg.SNSTopicARN) {
+		return nil, fmt.Errorf("SNS_TOPIC_ARN %q is not an SNS topic ARN", cfg.SNSTopicARN)
+	}
+	cfg.SNSMessageFormat = strings.ToLower(envOrDefault("SNS_MESSAGE_FORMAT", "text"))
+	if cfg.SNSMessageFormat != "text" && cfg.SNSMessageFormat != "json" {
+		return nil, fmt.Errorf("SNS_MESSAGE_FORMAT must be text or json, got %q", cfg.SNSMessageFormat)
+	}
+
+	cfg.TTSURL = os.Getenv("TTS_URL")
+	cfg.WeatherAPIURL = os.Getenv("WEATHER_API_URL")
+	cfg.WeatherAPIKey = os.Getenv("WEATHER_API_KEY")
+	cfg.RoutingAPIURL = os.Getenv("ROUTING_API_URL")
+	if cfg.RoutingAPIURL != "" {
+		station := os.Getenv("ROUTING_STATION")
+		if station == "" {
+			return nil, errors.New("ROUTING_STATION must be set when ROUTING_API_URL is")
+		}
+		if cfg.RoutingStation, err = parseStation(station); err != nil {
+			return nil, fmt.Errorf("ROUTING_STATION: %w", err)
+		}
+	}
+
+	cfg.GeocoderURL = os.Getenv("GEOCODER_URL")
+	cfg.GeocodeSuffix = os.Getenv("GEOCODE_SUFFIX")
+	// Public Nominatim allows at most one request per second.
+	if cfg.GeocodeInterval, err = envDuration("GEOCODE_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseEmbedFields splits a comma-separated field list, dropping unknown names with a warning.
+func parseEmbedFields(value string) []string {
+	var names []string
+	for _, name := range splitList(value) {
+		name = strings.ToLower(name)
+		if _, ok := embedFieldBuilders[name]; !ok {
+			log.Printf("Warning: ignoring unknown EMBED_FIELDS entry %q", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// envDuration parses a Go duration from the named environment variable.
+func envDuration(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return d, nil
+}
+
+// envInt parses a non-negative integer from the named environment variable.
+func envInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return n, nil
+}
+
+// envSize parses a byte count such as "500000", "512KB" or "10MB" from the
+// named environment variable; unset means 0.
+func envSize(name string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(name)))
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return n * multiplier, nil
+}
+
+// envFloat parses a non-negative number from the named environment variable.
+func envFloat(name string, fallback float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return f, nil
+}
+
+// envColor parses an embed color from the named environment variable.
+func envColor(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	color, err := parseColor(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return color, nil
+}
+
+// envOrDefault returns the named environment variable, or fallback when it is unset or empty.
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// setRequiredEnv sets the variables loadConfig needs before anything else.
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("RWECC_URL", "http://127.0.0.1/feed")
+	t.Setenv("RWECC_DISCORD_HOOK", "http://127.0.0.1/hook")
+}
+
+func TestLoadConfigSignificantFields(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{"jurisdiction,id", ""},
+		{"LAT,long", ""},
+		{"problem", "part of the fuzzy key"},
+		{"jurisdiction,address", "part of the fuzzy key"},
+		{"colour", "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("FUZZY_DEDUP_WINDOW", "1h")
+			t.Setenv("SIGNIFICANT_FIELDS", tt.value)
+			_, err := loadConfig()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("loadConfig: %s", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("loadConfig error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAPIMethod(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "GET", false},
+		{"post", "POST", false},
+		{"PUT", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("API_METHOD", tt.value)
+			cfg, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.APIMethod != tt.want {
+				t.Errorf("APIMethod = %q, want %q", cfg.APIMethod, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMapType(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "roadmap", false},
+		{"Satellite", "satellite", false},
+		{"hybrid", "hybrid", false},
+		{"terrain", "terrain", false},
+		{"street", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("MAP_TYPE", tt.value)
+			cfg, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.MapType != tt.want {
+				t.Errorf("MapType = %q, want %q", cfg.MapType, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFutureTSPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "pass", false},
+		{"Clamp", "clamp", false},
+		{"flag", "flag", false},
+		{"drop", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("FUTURE_TS_POLICY", tt.value)
+			cfg, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.FutureTSPolicy != tt.want {
+				t.Errorf("FutureTSPolicy = %q, want %q", cfg.FutureTSPolicy, tt.want)
+			}
+		})
+	}
+}
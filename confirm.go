@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// confirm asks the operator to approve a destructive change described by
+// summary. assumeYes (--yes) skips the prompt; without it, a non-interactive
+// stdin is an error rather than a silent yes.
+func confirm(summary string, assumeYes bool) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false, errors.New("stdin is not a terminal; re-run with --yes to confirm")
+	}
+	fmt.Fprintf(os.Stderr, "%s. Continue? [y/N] ", summary)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
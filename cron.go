@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is one or more five-field cron expressions
+// ("minute hour day-of-month month day-of-week") separated by ";". The
+// daemon wakes at the earliest time any of them matches, so
+// "* 9-17 * * 1-5; */5 0-8,18-23 * * *" polls every minute during weekday
+// business hours and every five minutes overnight. Fields accept *, numbers, ranges (a-b), steps (*/n, a-b/n) and
+// comma-separated lists; day-of-week runs 0-6 from Sunday, with 7 also Sunday.
+type cronSchedule struct {
+	exprs []cronExpr
+	loc   *time.Location
+	text  string
+}
+
+// cronExpr is a parsed expression; each field is a bitmask of allowed values.
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields: when both day
+	// fields are restricted, cron matches either of them.
+	domStar, dowStar bool
+}
+
+// cronFields gives the name and bounds of each field in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses POLL_CRON, evaluating it in loc.
+func parseCron(value string, loc *time.Location) (*cronSchedule, error) {
+	schedule := &cronSchedule{loc: loc, text: value}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		expr, err := parseCronExpr(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		schedule.exprs = append(schedule.exprs, expr)
+	}
+	if len(schedule.exprs) == 0 {
+		return nil, errors.New("no cron expressions")
+	}
+	return schedule, nil
+}
+
+// parseCronExpr parses a single five-field expression.
+func parseCronExpr(text string) (cronExpr, error) {
+	parts := strings.Fields(text)
+	if len(parts) != len(cronFields) {
+		return cronExpr{}, fmt.Errorf("want %d fields, got %d", len(cronFields), len(parts))
+	}
+	var masks [5]uint64
+	for i, part := range parts {
+		mask, err := parseCronField(part, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return cronExpr{}, fmt.Errorf("%s: %w", cronFields[i].name, err)
+		}
+		masks[i] = mask
+	}
+	// Fold 7 into 0 so Sunday has a single bit.
+	if masks[4]&(1<<7) != 0 {
+		masks[4] = masks[4]&^(1<<7) | 1
+	}
+	return cronExpr{
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField turns a comma-separated field into a bitmask of values.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			rangePart, step = item[:i], n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", item)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+// matches reports whether the minute starting at t is scheduled.
+func (e cronExpr) matches(t time.Time) bool {
+	if e.minute&(1<<t.Minute()) == 0 || e.hour&(1<<t.Hour()) == 0 || e.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := e.dom&(1<<t.Day()) != 0
+	dowOK := e.dow&(1<<int(t.Weekday())) != 0
+	if !e.domStar && !e.dowStar {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+// cronSearchLimit bounds Next for expressions that can never match, such
+// as 30 February.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// Next returns the first scheduled minute strictly after now, or the zero
+// time when nothing matches within five years.
+func (s *cronSchedule) Next(now time.Time) time.Time {
+	t := now.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		for _, expr := range s.exprs {
+			if expr.matches(t) {
+				return t
+			}
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// String returns the expression as configured.
+func (s *cronSchedule) String() string {
+	return s.text
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Dedup key strategies selectable with DEDUP_STRATEGY:
+//
+//   - timestamp_address (default): the incident timestamp and address, the
+//     key this tool has always used.
+//   - id: the feed's incident id, falling back to timestamp_address for
+//     incidents without one.
+//   - coords_time: the timestamp plus coordinates, for feeds whose address
+//     text varies between fetches.
+//   - composite: DEDUP_TEMPLATE, a Go template over the Incident fields,
+//     e.g. "{{.Timestamp}}|{{.Address}}|{{.Problem}}".
+const (
+	dedupTimestampAddress = "timestamp_address"
+	dedupID               = "id"
+	dedupCoordsTime       = "coords_time"
+	dedupComposite        = "composite"
+)
+
+// newKeyFunc builds the function that derives an incident's dedup key.
+func newKeyFunc(strategy, templateText string) (func(Incident) string, error) {
+	switch strategy {
+	case dedupTimestampAddress:
+		return timestampAddressKey, nil
+	case dedupID:
+		return func(incident Incident) string {
+			if incident.ID == "" {
+				return timestampAddressKey(incident)
+			}
+			return "id:" + incident.ID
+		}, nil
+	case dedupCoordsTime:
+		return func(incident Incident) string {
+			return fmt.Sprintf("%s %.6f,%.6f", incident.Timestamp, incident.Lat, incident.Long)
+		}, nil
+	case dedupComposite:
+		if templateText == "" {
+			return nil, fmt.Errorf("DEDUP_TEMPLATE must be set when DEDUP_STRATEGY=%s", dedupComposite)
+		}
+		tmpl, err := template.New("dedup").Parse(templateText)
+		if err != nil {
+			return nil, fmt.Errorf("parsing DEDUP_TEMPLATE: %w", err)
+		}
+		// Render once up front so references to unknown fields fail at startup.
+		if err := tmpl.Execute(&strings.Builder{}, Incident{}); err != nil {
+			return nil, fmt.Errorf("DEDUP_TEMPLATE: %w", err)
+		}
+		return func(incident Incident) string {
+			var b strings.Builder
+			if err := tmpl.Execute(&b, incident); err != nil {
+				return timestampAddressKey(incident)
+			}
+			return b.String()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown DEDUP_STRATEGY %q", strategy)
+	}
+}
+
+// withTimestampRounding wraps a key function so timestamps within the same
+// DEDUP_TIMESTAMP_ROUNDING step, e.g. 08:01:02.004 and 08:01:01.998 at 1s,
+// share a key. Timestamps that do not parse are keyed as they are.
+func withTimestampRounding(keyFor func(Incident) string, step time.Duration) func(Incident) string {
+	return func(incident Incident) string {
+		if at, err := time.Parse(incidentTimeLayout, incident.Timestamp); err == nil {
+			incident.Timestamp = at.Round(step).Format(incidentTimeLayout)
+		}
+		return keyFor(incident)
+	}
+}
+
+// timestampAddressKey is the original dedup key: timestamp and address.
+func timestampAddressKey(incident Incident) string {
+	return incident.Timestamp + " " + incident.Address
+}
+
+// dedupeByKey drops repeats of a key within one feed response, keeping the
+// first occurrence so the result does not depend on how the rest are ordered.
+// It returns the remaining incidents and how many were dropped.
+func dedupeByKey(incidents []Incident, keyFor func(Incident) string) ([]Incident, int) {
+	seen := make(map[string]bool, len(incidents))
+	unique := incidents[:0:0]
+	for _, incident := range incidents {
+		key := keyFor(incident)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, incident)
+	}
+	return unique, len(incidents) - len(unique)
+}
+
+// amendmentIdentity groups the versions of one call within a response: the
+// feed id when there is one, otherwise the address and coordinates.
+func amendmentIdentity(cfg *Config, incident Incident) (string, bool) {
+	if incident.ID != "" {
+		return "id:" + incident.ID, true
+	}
+	address := cfg.AddressAliases.canonical(incident.Address)
+	return fmt.Sprintf("loc:%s|%f,%f", normalizeAddress(address), incident.Lat, incident.Long), false
+}
+
+// mergeAmendments keeps only the newest version of each call the response
+// lists more than once with a changed problem, e.g. an original and an
+// amended entry. The newest is chosen by timestamp, with ties going to the
+// later entry. Calls without an id are only
+// merged when their problems differ, since repeats of the same problem at
+// one location may be separate calls. It returns the remaining incidents
+// and the superseded problem for each kept amendment, by key.
+func mergeAmendments(cfg *Config, incidents []Incident) ([]Incident, map[string]string) {
+	groups := make(map[string][]int)
+	var order []string
+	for i, incident := range incidents {
+		identity, _ := amendmentIdentity(cfg, incident)
+		if groups[identity] == nil {
+			order = append(order, identity)
+		}
+		groups[identity] = append(groups[identity], i)
+	}
+
+	drop := make(map[int]bool)
+	amended := make(map[string]string)
+	for _, identity := range order {
+		members := groups[identity]
+		if len(members) < 2 {
+			continue
+		}
+		newest := members[0]
+		problems := map[string]bool{normalizeProblem(incidents[newest].Problem): true}
+		for _, i := range members[1:] {
+			problems[normalizeProblem(incidents[i].Problem)] = true
+			if !incidentNewer(incidents[newest], incidents[i]) {
+				newest = i
+			}
+		}
+		if _, byID := amendmentIdentity(cfg, incidents[newest]); !byID && len(problems) < 2 {
+			continue
+		}
+		// The superseded problem is the newest earlier version's that differs.
+		var previous *Incident
+		for _, i := range members {
+			if i == newest {
+				continue
+			}
+			drop[i] = true
+			if normalizeProblem(incidents[i].Problem) != normalizeProblem(incidents[newest].Problem) &&
+				(previous == nil || !incidentNewer(*previous, incidents[i])) {
+				previous = &incidents[i]
+			}
+		}
+		if previous != nil {
+			amended[cfg.KeyFor(incidents[newest])] = previous.Problem
+		}
+	}
+	if len(drop) == 0 {
+		return incidents, amended
+	}
+
+	merged := make([]Incident, 0, len(incidents)-len(drop))
+	for i, incident := range incidents {
+		if !drop[i] {
+			merged = append(merged, incident)
+		}
+	}
+	return merged, amended
+}
+
+// incidentNewer reports whether a is timestamped strictly after b. An
+// unparseable timestamp is never newer.
+func incidentNewer(a, b Incident) bool {
+	at, errA := time.Parse(incidentTimeLayout, a.Timestamp)
+	bt, errB := time.Parse(incidentTimeLayout, b.Timestamp)
+	return errA == nil && (errB != nil || at.After(bt))
+}
+
+// fuzzyKey identifies "the same problem at the same address" regardless of
+// timestamp, for FUZZY_DEDUP_WINDOW suppression. ADDRESS_ALIASES apply first.
+func fuzzyKey(cfg *Config, incident Incident) string {
+	address := cfg.AddressAliases.canonical(incident.Address)
+	return "fuzzy:" + normalizeAddress(address) + "|" + normalizeProblem(incident.Problem)
+}
+
+// recentlyAlerted reports whether the fuzzy key for an incident was alerted
+// within FUZZY_DEDUP_WINDOW, returning how long ago.
+func recentlyAlerted(store StateStore, cfg *Config, incident Incident) (bool, time.Duration, error) {
+	at, ok, err := store.MarkedAt(fuzzyKey(cfg, incident))
+	if err != nil || !ok {
+		return false, 0, err
+	}
+	age := time.Since(at)
+	return age < cfg.FuzzyDedupWindow, age, nil
+}
+
+// locationKey is the state entry for an incident's LOCATION_COOLDOWN bucket:
+// its coordinates rounded to LOCATION_COOLDOWN_PRECISION decimal places,
+// about 110 m at the default of 3. Incidents without coordinates have none.
+func locationKey(cfg *Config, incident Incident) (string, bool) {
+	if incident.Lat == 0 && incident.Long == 0 {
+		return "", false
+	}
+	p := cfg.LocationCooldownPrecision
+	return fmt.Sprintf("location:%.*f,%.*f", p, incident.Lat, p, incident.Long), true
+}
+
+// locationCoolingDown reports whether an alert went out for the incident's
+// location bucket within LOCATION_COOLDOWN_MINUTES, returning how long ago.
+func locationCoolingDown(store StateStore, cfg *Config, incident Incident) (bool, time.Duration, error) {
+	key, ok := locationKey(cfg, incident)
+	if !ok {
+		return false, 0, nil
+	}
+	at, ok, err := store.MarkedAt(key)
+	if err != nil || !ok {
+		return false, 0, err
+	}
+	age := time.Since(at)
+	return age < cfg.LocationCooldown, age, nil
+}
+
+// significantFields are the incident fields SIGNIFICANT_FIELDS may name.
+// The problem and address are not among them: they make up the fuzzy key,
+// so a change to either is already a different incident and alerts.
+var significantFields = map[string]func(Incident) string{
+	"jurisdiction": func(i Incident) string { return i.Jurisdiction },
+	"id":           func(i Incident) string { return i.ID },
+	"lat":          func(i Incident) string { return strconv.FormatFloat(i.Lat, 'f', -1, 64) },
+	"long":         func(i Incident) string { return strconv.FormatFloat(i.Long, 'f', -1, 64) },
+	"media_url":    func(i Incident) string { return i.MediaURL },
+}
+
+// fieldKey is the state entry recording that an alert under the incident's
+// fuzzy key carried this value for field.
+func fieldKey(cfg *Config, incident Incident, field string) string {
+	return fuzzyKey(cfg, incident) + "#" + field + "=" + significantFields[field](incident)
+}
+
+// changedFields returns the SIGNIFICANT_FIELDS whose current value was not
+// alerted under the incident's fuzzy key within FUZZY_DEDUP_WINDOW. A
+// reissue that only moves the timestamp changes none of them.
+func changedFields(store StateStore, cfg *Config, incident Incident) ([]string, error) {
+	var changed []string
+	for _, field := range cfg.SignificantFields {
+		at, ok, err := store.MarkedAt(fieldKey(cfg, incident, field))
+		if err != nil {
+			return nil, err
+		}
+		if !ok || time.Since(at) >= cfg.FuzzyDedupWindow {
+			changed = append(changed, field)
+		}
+	}
+	return changed, nil
+}
+
+// ignorePrefix starts the state entries that --ignore adds.
+const ignorePrefix = "ignore:"
+
+// ignoreKey is the state entry that marks an incident key as ignored via --ignore.
+func ignoreKey(key string) string {
+	return ignorePrefix + key
+}
+
+// permanentEntry reports whether a stored key, with or without a
+// DEDUP_NAMESPACE, is an --ignore entry. Those are exempt from STATE_TTL,
+// compaction and --prune-state, since ignoring is meant to be permanent.
+func permanentEntry(stored string) bool {
+	if strings.HasPrefix(stored, ignorePrefix) {
+		return true
+	}
+	namespace, rest, ok := strings.Cut(stored, "/")
+	return ok && dedupNamespaceRe.MatchString(namespace) && strings.HasPrefix(rest, ignorePrefix)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChangedFieldsUnsuppresses(t *testing.T) {
+	cfg := &Config{FuzzyDedupWindow: time.Hour, SignificantFields: []string{"jurisdiction", "id"}}
+	first := Incident{ID: "1", Jurisdiction: "Raleigh", Problem: "MVC PI", Address: "100 Main St", Timestamp: "2025-09-26 08:01:02.000"}
+	tests := []struct {
+		name    string
+		reissue func(Incident) Incident
+		changed []string
+	}{
+		{"timestamp jitter", func(i Incident) Incident { i.Timestamp = "2025-09-26 08:01:02.417"; return i }, nil},
+		{"address spacing", func(i Incident) Incident { i.Address = "100  MAIN ST"; return i }, nil},
+		{"jurisdiction changed", func(i Incident) Incident { i.Jurisdiction = "Garner"; return i }, []string{"jurisdiction"}},
+		{"id changed", func(i Incident) Incident { i.ID = "2"; return i }, []string{"id"}},
+		{"both changed", func(i Incident) Incident { i.ID, i.Jurisdiction = "2", "Garner"; return i }, []string{"jurisdiction", "id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newFileStore(filepath.Join(t.TempDir(), "state.json"), time.UTC, 0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// What the send loop records after delivering the first alert.
+			store.Touch(fuzzyKey(cfg, first))
+			for _, field := range cfg.SignificantFields {
+				store.Touch(fieldKey(cfg, first, field))
+			}
+
+			reissue := tt.reissue(first)
+			recent, _, err := recentlyAlerted(store, cfg, reissue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !recent {
+				t.Fatal("reissue does not share the fuzzy key")
+			}
+			changed, err := changedFields(store, cfg, reissue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(changed, tt.changed) {
+				t.Errorf("changedFields = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestWithTimestampRounding(t *testing.T) {
+	tests := []struct {
+		name   string
+		step   time.Duration
+		a, b   string
+		shared bool
+	}{
+		{"jitter across a second", time.Second, "2025-09-26 08:01:02.004", "2025-09-26 08:01:01.998", true},
+		{"same second", time.Second, "2025-09-26 08:01:02.100", "2025-09-26 08:01:02.300", true},
+		{"different seconds", time.Second, "2025-09-26 08:01:02.000", "2025-09-26 08:01:04.000", false},
+		{"within a minute step", time.Minute, "2025-09-26 08:01:02.000", "2025-09-26 08:01:20.000", true},
+		{"unparsable kept as is", time.Second, "yesterday", "yesterday", true},
+		{"unparsable differs", time.Second, "yesterday", "today", false},
+	}
+	for _, tt := range tests {
+		keyFor := withTimestampRounding(timestampAddressKey, tt.step)
+		a := keyFor(Incident{Timestamp: tt.a, Address: "100 Main St"})
+		b := keyFor(Incident{Timestamp: tt.b, Address: "100 Main St"})
+		if (a == b) != tt.shared {
+			t.Errorf("%s: keys %q and %q, want shared %v", tt.name, a, b, tt.shared)
+		}
+	}
+
+	incident := Incident{Timestamp: "2025-09-26 08:01:02.004", Address: "100 Main St"}
+	if got, want := withTimestampRounding(timestampAddressKey, time.Second)(incident), "2025-09-26 08:01:02.000 100 Main St"; got != want {
+		t.Errorf("rounded key = %q, want %q", got, want)
+	}
+	if incident.Timestamp != "2025-09-26 08:01:02.004" {
+		t.Error("rounding modified the incident")
+	}
+}
+
+func TestDedupeByKey(t *testing.T) {
+	a := Incident{Problem: "MVC PI", Timestamp: "t1", Address: "100 Main St"}
+	aAgain := Incident{Problem: "MVC PI AMENDED", Timestamp: "t1", Address: "100 Main St"}
+	b := Incident{Problem: "MVC", Timestamp: "t2", Address: "5 Oak Ave"}
+	tests := []struct {
+		name    string
+		in      []Incident
+		want    []string
+		dropped int
+	}{
+		{"empty", nil, nil, 0},
+		{"no repeats", []Incident{a, b}, []string{"MVC PI", "MVC"}, 0},
+		{"keeps first", []Incident{a, b, aAgain}, []string{"MVC PI", "MVC"}, 1},
+		{"order decides", []Incident{aAgain, a, b}, []string{"MVC PI AMENDED", "MVC"}, 1},
+	}
+	for _, tt := range tests {
+		in := slices.Clone(tt.in)
+		unique, dropped := dedupeByKey(in, timestampAddressKey)
+		var problems []string
+		for _, incident := range unique {
+			problems = append(problems, incident.Problem)
+		}
+		if !slices.Equal(problems, tt.want) || dropped != tt.dropped {
+			t.Errorf("%s: got %v (dropped %d), want %v (dropped %d)", tt.name, problems, dropped, tt.want, tt.dropped)
+		}
+		for i := range in {
+			if in[i].Problem != tt.in[i].Problem {
+				t.Errorf("%s: input was modified", tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// loadSnapshot reads a saved API response and indexes its incidents by key,
+// keeping the first of any duplicates as runCycle does.
+func loadSnapshot(cfg *Config, filename string) (map[string]Incident, error) {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := decodeIncidents(cfg, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	incidents, _ = dedupeByKey(incidents, cfg.KeyFor)
+	byKey := make(map[string]Incident, len(incidents))
+	for _, incident := range incidents {
+		byKey[cfg.KeyFor(incident)] = incident
+	}
+	return byKey, nil
+}
+
+// diffSnapshots prints the incident keys that appeared, disappeared or
+// changed problem text between two saved API responses, for --diff.
+func diffSnapshots(cfg *Config, before, after string) error {
+	a, err := loadSnapshot(cfg, before)
+	if err != nil {
+		return err
+	}
+	b, err := loadSnapshot(cfg, after)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var appeared, disappeared, changed, unchanged int
+	for _, key := range keys {
+		old, inA := a[key]
+		cur, inB := b[key]
+		switch {
+		case !inA:
+			appeared++
+			fmt.Printf("+ %s: %s at %s\n", key, cur.Problem, cur.Address)
+		case !inB:
+			disappeared++
+			fmt.Printf("- %s: %s at %s\n", key, old.Problem, old.Address)
+		case old.Problem != cur.Problem:
+			changed++
+			note := ""
+			if problemChanged(old.Problem, cur.Problem, cfg.UpdateThreshold) {
+				note = " (would send an update)"
+			}
+			fmt.Printf("~ %s: %s -> %s%s\n", key, old.Problem, cur.Problem, note)
+		default:
+			unchanged++
+		}
+	}
+	fmt.Printf("%d appeared, %d disappeared, %d changed, %d unchanged\n", appeared, disappeared, changed, unchanged)
+	return nil
+}
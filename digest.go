@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestContentLimit keeps the digest inside Discord's 2000 character
+// message limit, leaving room for the "and N more" line.
+const digestContentLimit = 1900
+
+// severityRank orders severities from most to least serious.
+var severityRank = map[Severity]int{SeverityInjury: 0, SeverityDamage: 1, SeverityOther: 2}
+
+// digestRow is one line of the digest: a single incident in list mode, or a
+// problem and how often it occurred in counts mode.
+type digestRow struct {
+	problem  string
+	severity Severity
+	count    int
+	latest   time.Time
+	line     string
+}
+
+// buildDigest summarises the archived alerts per DIGEST_MODE, DIGEST_SORT and
+// DIGEST_LIMIT.
+func buildDigest(cfg *Config, records []ArchiveRecord) string {
+	counts := map[string]int{}
+	for _, record := range records {
+		counts[record.Incident.Problem]++
+	}
+
+	var rows []digestRow
+	if cfg.DigestMode == "counts" {
+		byProblem := map[string]*digestRow{}
+		for _, record := range records {
+			problem := record.Incident.Problem
+			row, ok := byProblem[problem]
+			if !ok {
+				row = &digestRow{problem: problem, severity: severityOf(cfg, record.Incident)}
+				byProblem[problem] = row
+			}
+			row.count++
+			if record.SentAt.After(row.latest) {
+				row.latest = record.SentAt
+			}
+		}
+		for _, row := range byProblem {
+			row.line = fmt.Sprintf("• **%s** — %d", displayProblem(cfg, row.problem), row.count)
+			rows = append(rows, *row)
+		}
+	} else {
+		for _, record := range records {
+			incident := record.Incident
+			rows = append(rows, digestRow{
+				problem:  incident.Problem,
+				severity: severityOf(cfg, incident),
+				count:    counts[incident.Problem],
+				latest:   record.SentAt,
+				line: fmt.Sprintf("• %s — **%s** at %s (%s)", record.SentAt.In(cfg.Timezone).Format("Mon 3:04 PM"),
+					displayProblem(cfg, incident.Problem), displayAddress(cfg, incident), incident.Jurisdiction),
+			})
+		}
+	}
+
+	// Every order breaks ties newest first, then by problem, so the
+	// output is stable.
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch cfg.DigestSort {
+		case "count":
+			if a.count != b.count {
+				return a.count > b.count
+			}
+		case "severity":
+			if severityRank[a.severity] != severityRank[b.severity] {
+				return severityRank[a.severity] < severityRank[b.severity]
+			}
+		}
+		if !a.latest.Equal(b.latest) {
+			return a.latest.After(b.latest)
+		}
+		return a.problem < b.problem
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Digest:** %d alerts in the last %s\n", len(records), windowLabel(cfg.DigestWindow))
+	if len(rows) == 0 {
+		b.WriteString("No incidents.")
+	}
+	for i, row := range rows {
+		if i == cfg.DigestLimit || b.Len()+len(row.line) > digestContentLimit {
+			fmt.Fprintf(&b, "…and %d more", len(rows)-i)
+			break
+		}
+		b.WriteString(row.line + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// postDigest reads the last DIGEST_WINDOW of the archive and posts the
+// digest to DIGEST_WEBHOOK.
+func postDigest(client *http.Client, cfg *Config) error {
+	records, err := readArchiveSince(cfg, time.Now().Add(-cfg.DigestWindow))
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	if err := postContent(client, cfg.DigestWebhookURL, buildDigest(cfg, records)); err != nil {
+		return err
+	}
+	log.Printf("Posted digest of %d alerts.", len(records))
+	return nil
+}
+
+// nextDigestAt returns the next DIGEST_AT time of day after now, in TIMEZONE.
+func nextDigestAt(cfg *Config, now time.Time) time.Time {
+	at, _ := time.Parse("15:04", cfg.DigestAt)
+	local := now.In(cfg.Timezone)
+	next := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, cfg.Timezone)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
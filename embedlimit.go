@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Discord's message limits, in characters. A payload over any of them is
+// rejected whole with a 400.
+const (
+	discordContentLimit     = 2000
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldCountLimit  = 25
+	discordFieldNameLimit   = 256
+	discordFieldValueLimit  = 1024
+	discordFooterLimit      = 2048
+	discordEmbedTotalLimit  = 6000
+)
+
+// fitPayload trims a payload in place so Discord accepts it, and reports
+// whether anything was cut. Long text is shortened with an ellipsis, and
+// fields that still do not fit are dropped from the end and counted in a
+// closing field, so the alert itself always goes out.
+func fitPayload(payload *DiscordWebhookPayload) bool {
+	trimmed := false
+	cut := func(s *string, limit int) {
+		if utf8.RuneCountInString(*s) > limit {
+			*s = string([]rune(*s)[:limit-1]) + "…"
+			trimmed = true
+		}
+	}
+	cut(&payload.Content, discordContentLimit)
+	for i := range payload.Embeds {
+		embed := &payload.Embeds[i]
+		cut(&embed.Title, discordTitleLimit)
+		cut(&embed.Description, discordDescriptionLimit)
+		cut(&embed.Footer.Text, discordFooterLimit)
+		for j := range embed.Fields {
+			cut(&embed.Fields[j].Name, discordFieldNameLimit)
+			cut(&embed.Fields[j].Value, discordFieldValueLimit)
+		}
+
+		omitted := 0
+		if len(embed.Fields) > discordFieldCountLimit {
+			omitted = len(embed.Fields) - (discordFieldCountLimit - 1)
+			embed.Fields = embed.Fields[:discordFieldCountLimit-1]
+		}
+		tooLong := func() bool {
+			n := embedLength(embed)
+			if omitted > 0 {
+				n += fieldLength(omittedField(omitted))
+			}
+			return n > discordEmbedTotalLimit
+		}
+		for len(embed.Fields) > 0 && tooLong() {
+			embed.Fields = embed.Fields[:len(embed.Fields)-1]
+			omitted++
+		}
+		if omitted > 0 {
+			embed.Fields = append(embed.Fields, omittedField(omitted))
+			trimmed = true
+		}
+		// Only a huge description can still be over; shorten it to fit.
+		if over := embedLength(embed) - discordEmbedTotalLimit; over > 0 {
+			cut(&embed.Description, max(utf8.RuneCountInString(embed.Description)-over, 1))
+		}
+	}
+	return trimmed
+}
+
+// omittedField notes how many fields fitPayload dropped.
+func omittedField(n int) EmbedField {
+	return EmbedField{Name: "Omitted", Value: fmt.Sprintf("%d more fields over Discord's size limit", n)}
+}
+
+// embedLength counts the characters Discord totals against the 6000 limit.
+func embedLength(embed *DiscordEmbed) int {
+	n := utf8.RuneCountInString(embed.Title) +
+		utf8.RuneCountInString(embed.Description) +
+		utf8.RuneCountInString(embed.Footer.Text)
+	for _, field := range embed.Fields {
+		n += fieldLength(field)
+	}
+	return n
+}
+
+func fieldLength(field EmbedField) int {
+	return utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+}
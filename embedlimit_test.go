@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFitPayload(t *testing.T) {
+	fields := func(n int, value string) []EmbedField {
+		out := make([]EmbedField, n)
+		for i := range out {
+			out[i] = EmbedField{Name: "Field", Value: value}
+		}
+		return out
+	}
+	tests := []struct {
+		name       string
+		embed      DiscordEmbed
+		content    string
+		trimmed    bool
+		fields     int
+		omitted    string
+		titleRunes int
+	}{
+		{
+			name:   "fits",
+			embed:  DiscordEmbed{Title: "MVC PI", Fields: fields(3, "x")},
+			fields: 3,
+		},
+		{
+			name:       "long title",
+			embed:      DiscordEmbed{Title: strings.Repeat("é", 300)},
+			trimmed:    true,
+			titleRunes: discordTitleLimit,
+		},
+		{
+			name:    "long content",
+			content: strings.Repeat("a", discordContentLimit+1),
+			embed:   DiscordEmbed{Title: "MVC"},
+			trimmed: true,
+		},
+		{
+			name:    "too many fields",
+			embed:   DiscordEmbed{Title: "MVC", Fields: fields(30, "x")},
+			trimmed: true,
+			fields:  discordFieldCountLimit,
+			omitted: "6 more fields",
+		},
+		{
+			name:    "over the total",
+			embed:   DiscordEmbed{Title: "MVC", Fields: fields(10, strings.Repeat("v", 1000))},
+			trimmed: true,
+			fields:  6,
+			omitted: "5 more fields",
+		},
+		{
+			name:    "long field value",
+			embed:   DiscordEmbed{Title: "MVC", Fields: fields(1, strings.Repeat("v", 2000))},
+			trimmed: true,
+			fields:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := DiscordWebhookPayload{Content: tt.content, Embeds: []DiscordEmbed{tt.embed}}
+			if got := fitPayload(&payload); got != tt.trimmed {
+				t.Errorf("trimmed = %v, want %v", got, tt.trimmed)
+			}
+			embed := payload.Embeds[0]
+			if n := utf8.RuneCountInString(payload.Content); n > discordContentLimit {
+				t.Errorf("content is %d characters", n)
+			}
+			if n := embedLength(&embed); n > discordEmbedTotalLimit {
+				t.Errorf("embed is %d characters", n)
+			}
+			if tt.titleRunes > 0 {
+				if n := utf8.RuneCountInString(embed.Title); n != tt.titleRunes || !strings.HasSuffix(embed.Title, "…") || !utf8.ValidString(embed.Title) {
+					t.Errorf("title is %d characters, want %d ending in an ellipsis", n, tt.titleRunes)
+				}
+			}
+			if tt.embed.Fields != nil && len(embed.Fields) != tt.fields {
+				t.Errorf("%d fields, want %d", len(embed.Fields), tt.fields)
+			}
+			for _, field := range embed.Fields {
+				if utf8.RuneCountInString(field.Value) > discordFieldValueLimit {
+					t.Errorf("field value is %d characters", utf8.RuneCountInString(field.Value))
+				}
+			}
+			if tt.omitted != "" {
+				last := embed.Fields[len(embed.Fields)-1]
+				if last.Name != "Omitted" || !strings.HasPrefix(last.Value, tt.omitted) {
+					t.Errorf("last field = %+v, want an Omitted note of %q", last, tt.omitted)
+				}
+			}
+		})
+	}
+}
+
+func TestFitPayloadHugeDescription(t *testing.T) {
+	payload := DiscordWebhookPayload{Embeds: []DiscordEmbed{{
+		Title:       strings.Repeat("t", discordTitleLimit),
+		Description: strings.Repeat("d", discordDescriptionLimit),
+		Footer:      EmbedFooter{Text: strings.Repeat("f", discordFooterLimit)},
+	}}}
+	if !fitPayload(&payload) {
+		t.Error("trimmed = false, want true")
+	}
+	if n := embedLength(&payload.Embeds[0]); n != discordEmbedTotalLimit {
+		t.Errorf("embed is %d characters, want exactly %d", n, discordEmbedTotalLimit)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "log"
+
+// Error categories counted in the end-of-cycle summary.
+const (
+	errFetch      = "fetch failure"
+	errFetchRetry = "fetch retry"
+	errParse      = "parse error"
+	errSend       = "send failure"
+	errNotify     = "notifier failure"
+	errState      = "state error"
+	errEnrich     = "enrichment error"
+	errArchive    = "archive error"
+	errDedupAPI   = "external dedup error"
+)
+
+// errorPlurals holds the category plurals that are not just an added "s".
+var errorPlurals = map[string]string{
+	errFetchRetry: "fetch retries",
+}
+
+// errorSummary counts the errors of one run or daemon cycle by category.
+type errorSummary map[string]int
+
+// add counts one error in category.
+func (e errorSummary) add(category string) {
+	e[category]++
+}
+
+// String renders the summary as "3 send failures, 1 parse error", most
+// frequent first.
+func (e errorSummary) String() string {
+	plural := make(dropSummary, len(e))
+	for category, n := range e {
+		if n != 1 {
+			if p, ok := errorPlurals[category]; ok {
+				category = p
+			} else {
+				category += "s"
+			}
+		}
+		plural[category] = n
+	}
+	return plural.String()
+}
+
+// report prints the summary for a "run" or "cycle", if there were any errors.
+func (e errorSummary) report(scope string) {
+	if len(e) == 0 {
+		return
+	}
+	total := 0
+	for _, n := range e {
+		total += n
+	}
+	noun := "errors"
+	if total == 1 {
+		noun = "error"
+	}
+	log.Printf("%d %s this %s: %s", total, noun, scope, e)
+}
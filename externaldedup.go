@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// externalDedupClient asks EXTERNAL_DEDUP_URL whether another reporting tool
+// already handled an alert. The endpoint is sent the dedup key as ?key= and
+// answers {"reported": true} or {"reported": false}.
+type externalDedupClient struct {
+	client   *http.Client
+	endpoint string
+	ttl      time.Duration
+	cache    map[string]externalDedupAnswer
+}
+
+// externalDedupAnswer is one cached response.
+type externalDedupAnswer struct {
+	reported bool
+	at       time.Time
+}
+
+// newExternalDedupClient returns a client for EXTERNAL_DEDUP_URL whose
+// answers are reused for ttl.
+func newExternalDedupClient(client *http.Client, endpoint string, ttl time.Duration) *externalDedupClient {
+	return &externalDedupClient{client: client, endpoint: endpoint, ttl: ttl, cache: make(map[string]externalDedupAnswer)}
+}
+
+// Reported reports whether key was handled elsewhere. Errors are not
+// cached, so an unreachable endpoint is retried on the next alert.
+func (e *externalDedupClient) Reported(key string) (bool, error) {
+	if answer, ok := e.cache[key]; ok && time.Since(answer.at) < e.ttl {
+		return answer.reported, nil
+	}
+
+	u, err := url.Parse(e.endpoint)
+	if err != nil {
+		return false, err
+	}
+	query := u.Query()
+	query.Set("key", key)
+	u.RawQuery = query.Encode()
+
+	resp, err := e.client.Get(u.String())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("external dedup endpoint returned non-2xx status: %s", resp.Status)
+	}
+
+	var result struct {
+		Reported *bool `json:"reported"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("decoding external dedup response: %w", err)
+	}
+	if result.Reported == nil {
+		return false, fmt.Errorf(`external dedup response has no "reported" field`)
+	}
+
+	e.cache[key] = externalDedupAnswer{reported: *result.Reported, at: time.Now()}
+	return *result.Reported, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Facility types in FACILITIES_FILE.
+const (
+	facilityHospital    = "hospital"
+	facilityFireStation = "fire_station"
+)
+
+// Facility is a named point from FACILITIES_FILE, a JSON array such as
+//
+//	[{"name": "WakeMed Raleigh", "type": "hospital", "lat": 35.7860, "long": -78.5870}]
+type Facility struct {
+	Name string  `json:"name"`
+	Type string  `json:"type"`
+	Lat  float64 `json:"lat"`
+	Long float64 `json:"long"`
+}
+
+// loadFacilities reads and validates FACILITIES_FILE.
+func loadFacilities(filename string) ([]Facility, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var facilities []Facility
+	if err := json.Unmarshal(data, &facilities); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if len(facilities) == 0 {
+		return nil, fmt.Errorf("%s: no facilities", filename)
+	}
+	for i, f := range facilities {
+		if f.Name == "" {
+			return nil, fmt.Errorf("%s: facility %d has no name", filename, i+1)
+		}
+		if f.Type != facilityHospital && f.Type != facilityFireStation {
+			return nil, fmt.Errorf("%s: %s: type must be %s or %s, got %q", filename, f.Name, facilityHospital, facilityFireStation, f.Type)
+		}
+		if f.Lat < -90 || f.Lat > 90 || f.Long < -180 || f.Long > 180 || (f.Lat == 0 && f.Long == 0) {
+			return nil, fmt.Errorf("%s: %s: invalid coordinates %v,%v", filename, f.Name, f.Lat, f.Long)
+		}
+	}
+	return facilities, nil
+}
+
+// earthRadiusMiles is the mean radius of the Earth.
+const earthRadiusMiles = 3958.8
+
+// distanceMiles is the great-circle distance between two points.
+func distanceMiles(a, b LatLng) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLong := lat2-lat1, radians(b.Long-a.Long)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h))
+}
+
+// facilityTypeFor returns the facility type relevant to an incident:
+// hospitals for injuries, fire stations for fires, and "" otherwise.
+func facilityTypeFor(cfg *Config, incident Incident) string {
+	if severityOf(cfg, incident) == SeverityInjury {
+		return facilityHospital
+	}
+	if strings.Contains(strings.ToLower(incident.Problem), "fire") {
+		return facilityFireStation
+	}
+	return ""
+}
+
+// nearestFacility returns the closest facility of the given type and its
+// distance in miles.
+func nearestFacility(facilities []Facility, kind string, at LatLng) (Facility, float64, error) {
+	var nearest Facility
+	best := math.Inf(1)
+	for _, f := range facilities {
+		if f.Type != kind {
+			continue
+		}
+		if d := distanceMiles(at, LatLng{Lat: f.Lat, Long: f.Long}); d < best {
+			nearest, best = f, d
+		}
+	}
+	if math.IsInf(best, 1) {
+		return Facility{}, 0, errors.New("no facilities of that type")
+	}
+	return nearest, best, nil
+}
+
+// facilityField is the embed field naming the nearest relevant facility,
+// if the incident has one. Incidents without coordinates or with a
+// redacted address get none, since the distance would hint at the location.
+func facilityField(cfg *Config, incident Incident) (EmbedField, bool) {
+	kind := facilityTypeFor(cfg, incident)
+	if kind == "" || (incident.Lat == 0 && incident.Long == 0) || addressRedacted(cfg, incident) {
+		return EmbedField{}, false
+	}
+	f, miles, err := nearestFacility(cfg.Facilities, kind, LatLng{Lat: incident.Lat, Long: incident.Long})
+	if err != nil {
+		return EmbedField{}, false
+	}
+	name := "Nearest Hospital"
+	if kind == facilityFireStation {
+		name = "Nearest Fire Station"
+	}
+	return EmbedField{Name: name, Value: fmt.Sprintf("%s (%.1f mi)", f.Name, miles), Inline: true}, true
+}
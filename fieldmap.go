@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// parseFieldMap parses FIELD_MAP, comma-separated field=key pairs such as
+// "long=lng,problem=type" naming the flat feed key each Incident field is
+// now read from.
+func parseFieldMap(value string) (map[string]string, error) {
+	fields := map[string]string{}
+	for _, entry := range splitList(value) {
+		field, key, ok := strings.Cut(entry, "=")
+		field, key = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid FIELD_MAP entry %q, want field=key", entry)
+		}
+		if _, known := arcgisDefaultFields[field]; !known {
+			return nil, fmt.Errorf("unknown FIELD_MAP field %q", field)
+		}
+		fields[field] = key
+	}
+	return fields, nil
+}
+
+// remapFields renames the mapped keys of each incident in a flat response
+// to the names Incident decodes, and warns about mapped keys that are
+// missing, since those fields would silently decode as empty or zero.
+func remapFields(body []byte, fieldMap map[string]string) ([]byte, error) {
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+	missing := map[string]int{}
+	for _, record := range records {
+		for field, key := range fieldMap {
+			raw, ok := record[key]
+			if !ok {
+				if _, present := record[field]; !present {
+					missing[field]++
+				}
+				continue
+			}
+			delete(record, key)
+			record[field] = raw
+		}
+	}
+
+	fields := make([]string, 0, len(missing))
+	for field := range missing {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		log.Printf("Warning: FIELD_MAP key %q (for %s) is missing from %d of %d incidents", fieldMap[field], field, missing[field], len(records))
+	}
+	return json.Marshal(records)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldMap(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"long=lng", map[string]string{"long": "lng"}, false},
+		{" Long = lng , problem=type", map[string]string{"long": "lng", "problem": "type"}, false},
+		{"long", nil, true},
+		{"long=", nil, true},
+		{"colour=color", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFieldMap(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFieldMap(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseFieldMap(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		for field, key := range tt.want {
+			if got[field] != key {
+				t.Errorf("parseFieldMap(%q)[%q] = %q, want %q", tt.value, field, got[field], key)
+			}
+		}
+	}
+}
+
+func TestDecodeIncidentsFieldMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		fieldMap string
+		body     string
+		problem  string
+		long     float64
+		warning  string
+	}{
+		{
+			name:     "renamed keys",
+			fieldMap: "long=lng,problem=type",
+			body:     `[{"type":"MVC PI","lat":35.78,"lng":-78.64}]`,
+			problem:  "MVC PI",
+			long:     -78.64,
+		},
+		{
+			name:     "old name still sent",
+			fieldMap: "long=lng",
+			body:     `[{"problem":"MVC PI","lat":35.78,"long":-78.64}]`,
+			problem:  "MVC PI",
+			long:     -78.64,
+		},
+		{
+			name:     "mapped key missing",
+			fieldMap: "long=lng",
+			body:     `[{"problem":"MVC PI","lat":35.78},{"problem":"MVC","lng":-78.7}]`,
+			problem:  "MVC PI",
+			warning:  `FIELD_MAP key "lng" (for long) is missing from 1 of 2 incidents`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldMap, err := parseFieldMap(tt.fieldMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			incidents, err := decodeIncidents(&Config{FieldMap: fieldMap}, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if incidents[0].Problem != tt.problem || incidents[0].Long != tt.long {
+				t.Errorf("got %+v, want problem %q long %v", incidents[0], tt.problem, tt.long)
+			}
+			if tt.warning == "" && logs.Len() > 0 {
+				t.Errorf("unexpected log: %s", logs.String())
+			}
+			if tt.warning != "" && !strings.Contains(logs.String(), tt.warning) {
+				t.Errorf("log %q, want %q", logs.String(), tt.warning)
+			}
+		})
+	}
+}
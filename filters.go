@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IncidentFilter is one stage of the filtering pipeline. Keep reports whether
+// an incident should continue down the pipeline and, if not, why.
+type IncidentFilter interface {
+	Keep(incident Incident) (bool, string)
+}
+
+// buildFilters assembles the pipeline from the configuration, in order.
+func buildFilters(cfg *Config) []IncidentFilter {
+	filters := []IncidentFilter{
+		matchFilter{expr: cfg.IncidentFilters},
+	}
+	if cfg.GeofencePolygon != nil {
+		filters = append(filters, geofenceFilter{polygon: cfg.GeofencePolygon})
+	}
+	if cfg.MinCoordPrecision > 0 && cfg.CoarseCoordsMode == "skip" {
+		filters = append(filters, precisionFilter{cfg: cfg})
+	}
+	return filters
+}
+
+// runFilters passes an incident through each stage, stopping at the first
+// that drops it.
+func runFilters(filters []IncidentFilter, incident Incident) (bool, string) {
+	for _, filter := range filters {
+		if keep, reason := filter.Keep(incident); !keep {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+// INCIDENT_FILTERS syntax:
+//
+//	term   = [field ":"] substring
+//	group  = term { " AND " term }
+//	filter = group { ("," | " OR ") group }
+//
+// A term matches when the field contains the substring, ignoring case. The
+// fields are problem, jurisdiction, address, id, and with PROBLEM_DELIMITER
+// type and modifier (any of the modifiers); a term without a known
+// field prefix matches problem, so the plain "MVC,FIRE" form still works.
+// AND binds tighter than OR, and the keywords must be upper case, e.g.
+// "problem:MVC AND jurisdiction:Raleigh, FIRE".
+var (
+	filterOrRe  = regexp.MustCompile(`\s+OR\s+`)
+	filterAndRe = regexp.MustCompile(`\s+AND\s+`)
+)
+
+// filterFields returns the incident field a filter term is matched against.
+var filterFields = map[string]func(Incident) string{
+	"problem":      func(i Incident) string { return i.Problem },
+	"jurisdiction": func(i Incident) string { return i.Jurisdiction },
+	"address":      func(i Incident) string { return i.Address },
+	"id":           func(i Incident) string { return i.ID },
+	"type":         func(i Incident) string { return i.Type },
+	"modifier":     func(i Incident) string { return strings.Join(i.Modifiers, "\n") },
+}
+
+// filterTerm is one field:substring test.
+type filterTerm struct {
+	field   string
+	pattern string
+}
+
+// filterExpr is a parsed INCIDENT_FILTERS: it matches when every term of
+// any one group matches.
+type filterExpr [][]filterTerm
+
+// parseFilterExpr parses the INCIDENT_FILTERS syntax described above.
+func parseFilterExpr(value string) (filterExpr, error) {
+	var expr filterExpr
+	for _, entry := range splitList(value) {
+		for _, group := range filterOrRe.Split(entry, -1) {
+			var terms []filterTerm
+			for _, term := range filterAndRe.Split(group, -1) {
+				term = strings.TrimSpace(term)
+				field, pattern := "problem", term
+				if name, rest, ok := strings.Cut(term, ":"); ok {
+					if _, known := filterFields[strings.ToLower(strings.TrimSpace(name))]; known {
+						field, pattern = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(rest)
+					}
+				}
+				if pattern == "" {
+					return nil, fmt.Errorf("invalid INCIDENT_FILTERS term %q in %q", term, entry)
+				}
+				terms = append(terms, filterTerm{field: field, pattern: pattern})
+			}
+			expr = append(expr, terms)
+		}
+	}
+	return expr, nil
+}
+
+// Match reports whether the incident satisfies the expression.
+func (e filterExpr) Match(incident Incident) bool {
+	return e.matchedGroup(incident) >= 0
+}
+
+// matchedGroup returns the index of the first group the incident satisfies, or -1.
+func (e filterExpr) matchedGroup(incident Incident) int {
+	for i, group := range e {
+		matched := true
+		for _, term := range group {
+			if !containsFold(filterFields[term.field](incident), term.pattern) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return i
+		}
+	}
+	return -1
+}
+
+// String renders the expression in its canonical form for logs.
+func (e filterExpr) String() string {
+	groups := make([]string, len(e))
+	for i, group := range e {
+		groups[i] = groupString(group)
+	}
+	return strings.Join(groups, " OR ")
+}
+
+// groupString renders one AND group, e.g. "problem:MVC AND jurisdiction:Cary".
+func groupString(group []filterTerm) string {
+	terms := make([]string, len(group))
+	for i, term := range group {
+		terms[i] = term.field + ":" + term.pattern
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// matchFilter keeps incidents matching INCIDENT_FILTERS.
+type matchFilter struct {
+	expr filterExpr
+}
+
+func (f matchFilter) Keep(incident Incident) (bool, string) {
+	if f.expr.Match(incident) {
+		return true, ""
+	}
+	return false, "does not match INCIDENT_FILTERS"
+}
+
+// dropSummary counts dropped incidents by reason for the end-of-cycle log.
+type dropSummary map[string]int
+
+// total is the number of incidents dropped for any reason.
+func (d dropSummary) total() int {
+	n := 0
+	for _, count := range d {
+		n += count
+	}
+	return n
+}
+
+// String renders the summary as "3 does not match INCIDENT_FILTERS, 1 ...",
+// most frequent reason first.
+func (d dropSummary) String() string {
+	reasons := make([]string, 0, len(d))
+	for reason := range d {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if d[reasons[i]] != d[reasons[j]] {
+			return d[reasons[i]] > d[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", d[reason], reason)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// testFilters runs the incidents in filename, a saved feed response in
+// API_FORMAT, through the pipeline and prints each verdict without sending.
+func testFilters(cfg *Config, filename string) error {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	incidents, err := decodeIncidents(cfg, body)
+	if err != nil {
+		return err
+	}
+
+	filters := buildFilters(cfg)
+	kept := 0
+	for _, incident := range incidents {
+		if keep, reason := runFilters(filters, incident); !keep {
+			fmt.Printf("DROP   %s at %s: %s\n", incident.Problem, incident.Address, reason)
+			continue
+		}
+		kept++
+		group := cfg.IncidentFilters[cfg.IncidentFilters.matchedGroup(incident)]
+		fmt.Printf("MATCH  %s at %s: matched %s\n", incident.Problem, incident.Address, groupString(group))
+	}
+	fmt.Printf("%d of %d incidents would alert with INCIDENT_FILTERS=%s\n", kept, len(incidents), cfg.IncidentFilters)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestParseFilterExpr(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"MVC", "problem:MVC", false},
+		{"MVC,FIRE", "problem:MVC OR problem:FIRE", false},
+		{"MVC OR FIRE", "problem:MVC OR problem:FIRE", false},
+		{"problem:MVC AND jurisdiction:Raleigh, FIRE", "problem:MVC AND jurisdiction:Raleigh OR problem:FIRE", false},
+		{"Jurisdiction: Cary  AND  type:MVC", "jurisdiction:Cary AND type:MVC", false},
+		{"CODE:10-50", "problem:CODE:10-50", false},
+		{"MVC and FIRE", "problem:MVC and FIRE", false},
+		{"problem:", "", true},
+		{"MVC AND jurisdiction:", "", true},
+	}
+	for _, tt := range tests {
+		expr, err := parseFilterExpr(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFilterExpr(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && expr.String() != tt.want {
+			t.Errorf("parseFilterExpr(%q) = %q, want %q", tt.value, expr.String(), tt.want)
+		}
+	}
+}
+
+func TestFilterExprMatch(t *testing.T) {
+	expr, err := parseFilterExpr("problem:MVC AND jurisdiction:Raleigh, FIRE, modifier:ENTRAPMENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		incident Incident
+		group    int
+	}{
+		{"both terms", Incident{Problem: "MVC PI", Jurisdiction: "Raleigh"}, 0},
+		{"case-insensitive", Incident{Problem: "mvc pi", Jurisdiction: "RALEIGH"}, 0},
+		{"one term of the AND", Incident{Problem: "MVC PI", Jurisdiction: "Cary"}, -1},
+		{"bare term", Incident{Problem: "STRUCTURE FIRE", Jurisdiction: "Cary"}, 1},
+		{"any modifier", Incident{Problem: "MVC", Modifiers: []string{"INJURY", "ENTRAPMENT"}}, 2},
+		{"no match", Incident{Problem: "ALARM", Jurisdiction: "Raleigh"}, -1},
+	}
+	for _, tt := range tests {
+		if got := expr.matchedGroup(tt.incident); got != tt.group {
+			t.Errorf("%s: matchedGroup = %d, want %d", tt.name, got, tt.group)
+		}
+		if got := expr.Match(tt.incident); got != (tt.group >= 0) {
+			t.Errorf("%s: Match = %v", tt.name, got)
+		}
+	}
+}
+
+func TestDropSummary(t *testing.T) {
+	d := dropSummary{"outside GEOFENCE_POLYGON": 1, "does not match INCIDENT_FILTERS": 3, "coarse coordinates": 1}
+	if got := d.total(); got != 5 {
+		t.Errorf("total = %d, want 5", got)
+	}
+	want := "3 does not match INCIDENT_FILTERS, 1 coarse coordinates, 1 outside GEOFENCE_POLYGON"
+	if got := d.String(); got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
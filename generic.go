@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultGenericTemplate posts the dedup key and the incident, both
+// redacted like every other copy that leaves the process.
+const defaultGenericTemplate = `{"key": {{json .Key}}, "incident": {{json .Incident}}}`
+
+// genericTemplateData is what GENERIC_WEBHOOK_TEMPLATE is rendered against.
+type genericTemplateData struct {
+	// Key is hashed for address-redacted incidents; see redactKey.
+	Key string
+	// Incident has REDACT_ADDRESS_FOR and NO_MAP_FOR applied; see redactIncident.
+	Incident Incident
+	Problem  string
+	Address  string
+	Severity Severity
+	Time     string
+	// Fields are the alert's fields at GENERIC_WEBHOOK_VERBOSITY.
+	Fields []EmbedField
+}
+
+// genericNotifier POSTs a templated JSON body to an arbitrary endpoint.
+type genericNotifier struct {
+	client  *http.Client
+	cfg     *Config
+	url     string
+	tmpl    *template.Template
+	headers http.Header
+}
+
+// newGenericNotifier parses the template and headers, and checks that the
+// template renders valid JSON for a sample incident.
+func newGenericNotifier(client *http.Client, cfg *Config) (*genericNotifier, error) {
+	tmpl, err := template.New("generic").Funcs(template.FuncMap{
+		// json encodes a value, so strings are quoted and escaped safely.
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(cfg.GenericWebhookTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing GENERIC_WEBHOOK_TEMPLATE: %w", err)
+	}
+
+	headers := make(http.Header)
+	for _, entry := range splitList(cfg.GenericWebhookHeaders) {
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid GENERIC_WEBHOOK_HEADERS entry %q, want Name: value", entry)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	n := &genericNotifier{client: client, cfg: cfg, url: cfg.GenericWebhookURL, tmpl: tmpl, headers: headers}
+	sample := pendingAlert{
+		key:        "sample",
+		incident:   Incident{Jurisdiction: "Raleigh", Problem: `MVC "PI"`, Address: "100 Main St", Timestamp: "2024-06-01 12:00:00.000"},
+		parsedTime: time.Now(),
+	}
+	if _, err := n.render(sample); err != nil {
+		return nil, fmt.Errorf("GENERIC_WEBHOOK_TEMPLATE: %w", err)
+	}
+	return n, nil
+}
+
+func (n *genericNotifier) Name() string {
+	return "generic webhook"
+}
+
+func (n *genericNotifier) Notify(alert pendingAlert) error {
+	body, err := n.render(alert)
+	if err != nil {
+		return err
+	}
+	_, err = postWebhook(n.client, n.cfg, n.url, body, n.headers)
+	return err
+}
+
+// render executes the template for an alert and checks the result is JSON.
+func (n *genericNotifier) render(alert pendingAlert) ([]byte, error) {
+	var b bytes.Buffer
+	err := n.tmpl.Execute(&b, genericTemplateData{
+		Key:      redactKey(n.cfg, alert.incident, alert.key),
+		Incident: redactIncident(n.cfg, alert.incident),
+		Problem:  displayProblem(n.cfg, alert.incident.Problem),
+		Address:  displayAddress(n.cfg, alert.incident),
+		Severity: severityOf(n.cfg, alert.incident),
+		Time:     alert.parsedTime.Format(time.RFC3339),
+		Fields:   buildEmbedFields(n.cfg, alert, n.cfg.GenericWebhookVerbosity),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("template did not render valid JSON: %s", b.String())
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericRenderRedacts(t *testing.T) {
+	cfg := withDefaultKeys(t, &Config{
+		GenericWebhookURL:      "http://127.0.0.1/hook",
+		GenericWebhookTemplate: defaultGenericTemplate,
+		RedactAddressFor:       []string{"ASSAULT"},
+		NoMapFor:               []string{"WELFARE"},
+	})
+	n, err := newGenericNotifier(http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		problem string
+		address string
+		lat     float64
+		rawKey  bool
+	}{
+		{"plain", "MVC PI", "12 Elm St", 35.7, true},
+		{"redacted address", "ASSAULT", "XXX Elm St", 0, false},
+		{"map hidden", "WELFARE CHECK", "12 Elm St", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incident := Incident{Problem: tt.problem, Address: "12 Elm St", Lat: 35.7, Long: -78.6, Timestamp: "2025-09-26 07:01:02.000"}
+			key := cfg.KeyFor(incident)
+			body, err := n.render(pendingAlert{key: key, incident: incident, parsedTime: time.Now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Key      string   `json:"key"`
+				Incident Incident `json:"incident"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Incident.Address != tt.address || got.Incident.Lat != tt.lat {
+				t.Errorf("incident = %+v, want address %q lat %v", got.Incident, tt.address, tt.lat)
+			}
+			if (got.Key == key) != tt.rawKey {
+				t.Errorf("key = %q, want raw key %v", got.Key, tt.rawKey)
+			}
+			if !tt.rawKey && strings.Contains(string(body), "12 Elm St") {
+				t.Errorf("body leaks the address: %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// geocoder resolves addresses to coordinates through a Nominatim-compatible
+// search endpoint, spacing requests by interval and caching by address.
+type geocoder struct {
+	client   *http.Client
+	endpoint string
+	suffix   string
+	interval time.Duration
+	last     time.Time
+	cache    map[string]*LatLng
+}
+
+// newGeocoder returns a geocoder for GEOCODER_URL.
+func newGeocoder(client *http.Client, cfg *Config) *geocoder {
+	return &geocoder{
+		client:   client,
+		endpoint: cfg.GeocoderURL,
+		suffix:   cfg.GeocodeSuffix,
+		interval: cfg.GeocodeInterval,
+		cache:    make(map[string]*LatLng),
+	}
+}
+
+// Lookup returns the coordinates for an address. A nil result with a nil
+// error means the geocoder had no match; misses are cached too.
+func (g *geocoder) Lookup(address string) (*LatLng, error) {
+	key := normalizeAddress(address)
+	if point, ok := g.cache[key]; ok {
+		return point, nil
+	}
+
+	if wait := g.interval - time.Since(g.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	g.last = time.Now()
+
+	u, err := url.Parse(g.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("q", address+g.suffix)
+	q.Set("format", "json")
+	q.Set("limit", "1")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Nominatim's usage policy requires an identifying User-Agent.
+	req.Header.Set("User-Agent", "911-reporting/"+version)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("geocoder returned non-2xx status: %s", resp.Status)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("decoding geocoder response: %w", err)
+	}
+
+	var point *LatLng
+	if len(results) > 0 {
+		lat, latErr := strconv.ParseFloat(results[0].Lat, 64)
+		long, longErr := strconv.ParseFloat(results[0].Lon, 64)
+		if latErr != nil || longErr != nil {
+			return nil, fmt.Errorf("geocoder returned invalid coordinates %q,%q", results[0].Lat, results[0].Lon)
+		}
+		point = &LatLng{Lat: lat, Long: long}
+	}
+	g.cache[key] = point
+	return point, nil
+}
+
+// normalizeAddress folds case and whitespace so equivalent addresses share a cache entry.
+func normalizeAddress(address string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(address), " "))
+}
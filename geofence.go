@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// polygon is a set of rings tested with the even-odd rule, so inner rings of
+// a GeoJSON polygon act as holes and multipolygon parts simply add area.
+type polygon [][]LatLng
+
+// parsePolygon reads GEOFENCE_POLYGON: inline GeoJSON, a path to a GeoJSON
+// file, or "lat,long;lat,long;..." vertices. GeoJSON may be a Polygon,
+// MultiPolygon, or a Feature wrapping one.
+func parsePolygon(value string) (polygon, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "{") && (strings.HasSuffix(value, ".json") || strings.HasSuffix(value, ".geojson")) {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		value = string(data)
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return parseGeoJSONPolygon([]byte(value))
+	}
+
+	var ring []LatLng
+	for _, vertex := range strings.Split(value, ";") {
+		if strings.TrimSpace(vertex) == "" {
+			continue
+		}
+		latText, longText, ok := strings.Cut(vertex, ",")
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+		long, longErr := strconv.ParseFloat(strings.TrimSpace(longText), 64)
+		if !ok || latErr != nil || longErr != nil {
+			return nil, fmt.Errorf("invalid vertex %q, want lat,long", vertex)
+		}
+		ring = append(ring, LatLng{Lat: lat, Long: long})
+	}
+	if len(ring) < 3 {
+		return nil, fmt.Errorf("need at least 3 vertices, got %d", len(ring))
+	}
+	return polygon{ring}, nil
+}
+
+// parseGeoJSONPolygon decodes a Polygon or MultiPolygon geometry, bare or in a
+// Feature. GeoJSON positions are [long, lat].
+func parseGeoJSONPolygon(data []byte) (polygon, error) {
+	var geo struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(data, &geo); err != nil {
+		return nil, err
+	}
+
+	var rings [][][]float64
+	switch geo.Type {
+	case "Feature":
+		return parseGeoJSONPolygon(geo.Geometry)
+	case "Polygon":
+		if err := json.Unmarshal(geo.Coordinates, &rings); err != nil {
+			return nil, err
+		}
+	case "MultiPolygon":
+		var parts [][][][]float64
+		if err := json.Unmarshal(geo.Coordinates, &parts); err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			rings = append(rings, part...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q, want Polygon or MultiPolygon", geo.Type)
+	}
+
+	var poly polygon
+	for _, positions := range rings {
+		if len(positions) < 3 {
+			return nil, fmt.Errorf("ring has %d positions, need at least 3", len(positions))
+		}
+		ring := make([]LatLng, 0, len(positions))
+		for _, position := range positions {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("invalid position %v", position)
+			}
+			ring = append(ring, LatLng{Lat: position[1], Long: position[0]})
+		}
+		poly = append(poly, ring)
+	}
+	if len(poly) == 0 {
+		return nil, fmt.Errorf("polygon has no rings")
+	}
+	return poly, nil
+}
+
+// pointInPolygon reports whether p is inside poly by ray casting: a ray from
+// p crosses the boundary an odd number of times only from inside.
+func pointInPolygon(p LatLng, poly polygon) bool {
+	inside := false
+	for _, ring := range poly {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+				p.Long < (b.Long-a.Long)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Long {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// geofenceFilter keeps incidents inside GEOFENCE_POLYGON. Incidents without
+// coordinates cannot be placed and are kept.
+type geofenceFilter struct {
+	polygon polygon
+}
+
+func (f geofenceFilter) Keep(incident Incident) (bool, string) {
+	if incident.Lat == 0 && incident.Long == 0 {
+		return true, ""
+	}
+	if pointInPolygon(LatLng{Lat: incident.Lat, Long: incident.Long}, f.polygon) {
+		return true, ""
+	}
+	return false, "outside GEOFENCE_POLYGON"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPointInPolygon(t *testing.T) {
+	square := polygon{{{0, 0}, {0, 10}, {10, 10}, {10, 0}}}
+	diamond := polygon{{{0, 5}, {5, 10}, {10, 5}, {5, 0}}}
+	withHole := polygon{
+		{{0, 0}, {0, 10}, {10, 10}, {10, 0}},
+		{{4, 4}, {4, 6}, {6, 6}, {6, 4}},
+	}
+	twoParts := polygon{
+		{{0, 0}, {0, 2}, {2, 2}, {2, 0}},
+		{{5, 5}, {5, 7}, {7, 7}, {7, 5}},
+	}
+	tests := []struct {
+		name string
+		poly polygon
+		p    LatLng
+		want bool
+	}{
+		{"inside", square, LatLng{5, 5}, true},
+		{"outside", square, LatLng{5, 15}, false},
+		{"outside below", square, LatLng{-1, 5}, false},
+		// Boundary points follow the half-open crossing rule: the south and
+		// west edges are inside, the north and east edges outside.
+		{"on west edge", square, LatLng{5, 0}, true},
+		{"on south edge", square, LatLng{0, 5}, true},
+		{"on east edge", square, LatLng{5, 10}, false},
+		{"on north edge", square, LatLng{10, 5}, false},
+		// The ray from these points passes exactly through vertices, which
+		// must be counted once, not twice.
+		{"ray through one vertex", diamond, LatLng{5, 5}, true},
+		{"ray through two vertices", diamond, LatLng{5, -1}, false},
+		{"near vertex outside", diamond, LatLng{9.9, 9.9}, false},
+		{"in hole", withHole, LatLng{5, 5}, false},
+		{"between hole and edge", withHole, LatLng{2, 2}, true},
+		{"first part", twoParts, LatLng{1, 1}, true},
+		{"second part", twoParts, LatLng{6, 6}, true},
+		{"between parts", twoParts, LatLng{3.5, 3.5}, false},
+	}
+	for _, tt := range tests {
+		if got := pointInPolygon(tt.p, tt.poly); got != tt.want {
+			t.Errorf("%s: pointInPolygon(%v) = %v, want %v", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestParsePolygon(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fence.geojson")
+	if err := os.WriteFile(file, []byte(`{"type":"Polygon","coordinates":[[[-78.7,35.7],[-78.6,35.7],[-78.6,35.8],[-78.7,35.7]]]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		rings   int
+		first   LatLng
+		wantErr bool
+	}{
+		{"vertices", "35.7,-78.7; 35.7,-78.6; 35.8,-78.6", 1, LatLng{35.7, -78.7}, false},
+		{"GeoJSON file", file, 1, LatLng{35.7, -78.7}, false},
+		{"Feature", `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[1,2],[3,4],[5,6]]]}}`, 1, LatLng{2, 1}, false},
+		{"MultiPolygon", `{"type":"MultiPolygon","coordinates":[[[[1,2],[3,4],[5,6]]],[[[7,8],[9,10],[11,12]],[[0,0],[1,1],[2,0]]]]}`, 3, LatLng{2, 1}, false},
+		{"too few vertices", "35.7,-78.7; 35.7,-78.6", 0, LatLng{}, true},
+		{"bad vertex", "35.7,-78.7; north; 35.8,-78.6", 0, LatLng{}, true},
+		{"unsupported type", `{"type":"Point","coordinates":[1,2]}`, 0, LatLng{}, true},
+		{"short ring", `{"type":"Polygon","coordinates":[[[1,2],[3,4]]]}`, 0, LatLng{}, true},
+		{"missing file", filepath.Join(t.TempDir(), "none.json"), 0, LatLng{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poly, err := parsePolygon(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(poly) != tt.rings || poly[0][0] != tt.first {
+				t.Errorf("got %d rings starting %v, want %d starting %v", len(poly), poly[0][0], tt.rings, tt.first)
+			}
+		})
+	}
+}
+
+func TestGeofenceFilterKeepsUnplacedIncidents(t *testing.T) {
+	f := geofenceFilter{polygon: polygon{{{0, 0}, {0, 10}, {10, 10}, {10, 0}}}}
+	tests := []struct {
+		incident Incident
+		want     bool
+	}{
+		{Incident{Lat: 5, Long: 5}, true},
+		{Incident{Lat: 50, Long: 5}, false},
+		{Incident{}, true},
+	}
+	for _, tt := range tests {
+		if got, _ := f.Keep(tt.incident); got != tt.want {
+			t.Errorf("Keep(%v, %v) = %v, want %v", tt.incident.Lat, tt.incident.Long, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// discordWebhooks returns every configured Discord webhook URL, by the
+// setting it came from.
+func discordWebhooks(cfg *Config) map[string]string {
+	webhooks := map[string]string{"RWECC_DISCORD_HOOK": cfg.WebhookURL}
+	optional := map[string]string{
+		"FALLBACK_WEBHOOK_URL": cfg.FallbackWebhookURL,
+		"ANOMALY_WEBHOOK":      cfg.AnomalyWebhookURL,
+		"OVERVIEW_WEBHOOK":     cfg.OverviewWebhookURL,
+		"STATS_WEBHOOK":        cfg.StatsWebhookURL,
+		"DIGEST_WEBHOOK":       cfg.DigestWebhookURL,
+		"OPS_WEBHOOK":          cfg.OpsWebhookURL,
+	}
+	for name, value := range optional {
+		if value != "" {
+			webhooks[name] = value
+		}
+	}
+	for _, route := range cfg.Routes {
+		webhooks["ROUTES "+route.Pattern] = route.WebhookURL
+	}
+	if cfg.Policy != nil {
+		for name, webhookURL := range cfg.Policy.Webhooks {
+			webhooks["--config webhook "+name] = webhookURL
+		}
+	}
+	return webhooks
+}
+
+// discordWebhookHosts are the hosts Discord serves webhooks from.
+var discordWebhookHosts = []string{"discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com"}
+
+// isDiscordWebhook reports whether a URL is a Discord webhook, which the
+// health check's GET probe only makes sense for. Other services, such as a
+// FALLBACK_WEBHOOK_URL pointing elsewhere, may reject a GET while healthy.
+func isDiscordWebhook(webhookURL string) bool {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	return slices.Contains(discordWebhookHosts, strings.ToLower(u.Hostname())) &&
+		strings.HasPrefix(u.Path, "/api/") && strings.Contains(u.Path, "/webhooks/")
+}
+
+// webhookHealth remembers which webhooks failed the last check, so OPS_WEBHOOK
+// hears about each one once when it starts failing and once when it recovers.
+type webhookHealth struct {
+	client  *http.Client
+	cfg     *Config
+	failing map[string]bool
+	// skipped holds the non-Discord URLs already logged as not checked.
+	skipped map[string]bool
+}
+
+// newWebhookHealth starts with every webhook presumed healthy.
+func newWebhookHealth(client *http.Client, cfg *Config) *webhookHealth {
+	return &webhookHealth{client: client, cfg: cfg, failing: make(map[string]bool), skipped: make(map[string]bool)}
+}
+
+// Check GETs each Discord webhook, which Discord answers with the webhook's
+// details without posting anything, so no channel sees the check. URLs for
+// other services are skipped.
+func (h *webhookHealth) Check() {
+	// Several settings often share one URL (ANOMALY_WEBHOOK defaults to
+	// RWECC_DISCORD_HOOK); check each URL once under all its names.
+	byURL := map[string][]string{}
+	for name, webhookURL := range discordWebhooks(h.cfg) {
+		if !isDiscordWebhook(webhookURL) {
+			if !h.skipped[webhookURL] {
+				h.skipped[webhookURL] = true
+				log.Printf("Webhook health: not checking %s, which is not a Discord webhook", name)
+			}
+			continue
+		}
+		byURL[webhookURL] = append(byURL[webhookURL], name)
+	}
+
+	var broke, recovered []string
+	for webhookURL, names := range byURL {
+		sort.Strings(names)
+		label := strings.Join(names, ", ")
+		err := checkWebhook(h.client, webhookURL)
+		switch {
+		case err != nil && !h.failing[webhookURL]:
+			h.failing[webhookURL] = true
+			log.Printf("Webhook health: %s is failing: %s", label, err)
+			broke = append(broke, fmt.Sprintf("%s (%s)", label, err))
+		case err != nil:
+			log.Printf("Webhook health: %s is still failing: %s", label, err)
+		case h.failing[webhookURL]:
+			delete(h.failing, webhookURL)
+			log.Printf("Webhook health: %s has recovered", label)
+			recovered = append(recovered, label)
+		}
+	}
+
+	if h.cfg.OpsWebhookURL == "" || (len(broke) == 0 && len(recovered) == 0) {
+		return
+	}
+	sort.Strings(broke)
+	sort.Strings(recovered)
+	var b strings.Builder
+	for _, line := range broke {
+		fmt.Fprintf(&b, "⚠️ Webhook failing: %s\n", line)
+	}
+	for _, line := range recovered {
+		fmt.Fprintf(&b, "✅ Webhook recovered: %s\n", line)
+	}
+	if err := postContent(h.client, h.cfg.OpsWebhookURL, strings.TrimSpace(b.String())); err != nil {
+		log.Printf("Error sending webhook health to OPS_WEBHOOK: %s", err)
+	}
+}
+
+// checkWebhook fetches a webhook's details. A 401 or 404 means it was
+// deleted or its token revoked.
+func checkWebhook(client *http.Client, webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = ""
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("webhook no longer exists (%s)", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("webhook returned non-2xx status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsDiscordWebhook(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://discord.com/api/webhooks/1/abc", true},
+		{"https://discordapp.com/api/webhooks/1/abc", true},
+		{"https://canary.discord.com/api/v10/webhooks/1/abc?wait=true", true},
+		{"https://Discord.com/api/webhooks/1/abc", true},
+		{"http://discord.com/api/webhooks/1/abc", false},
+		{"https://discord.com/channels/1/2", false},
+		{"https://discord.com.evil.example/api/webhooks/1/abc", false},
+		{"https://hooks.slack.com/services/T/B/x", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"::not a url", false},
+	}
+	for _, tt := range tests {
+		if got := isDiscordWebhook(tt.url); got != tt.want {
+			t.Errorf("isDiscordWebhook(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+// roundTripFunc lets a test answer requests without a network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestHealthCheckSkipsNonDiscordFallback(t *testing.T) {
+	var probed []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		probed = append(probed, r.URL.Host)
+		// A non-Discord fallback would reject the GET probe like this.
+		status := http.StatusOK
+		if r.URL.Host != "discord.com" {
+			status = http.StatusMethodNotAllowed
+		}
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: http.NoBody}, nil
+	})}
+	cfg := &Config{
+		WebhookURL:         "https://discord.com/api/webhooks/1/abc",
+		FallbackWebhookURL: "https://hooks.example.com/incoming",
+	}
+	h := newWebhookHealth(client, cfg)
+	for range 2 {
+		h.Check()
+	}
+	if len(probed) != 2 || probed[0] != "discord.com" || probed[1] != "discord.com" {
+		t.Errorf("probed %v, want only the Discord webhook each check", probed)
+	}
+	if len(h.failing) != 0 {
+		t.Errorf("failing = %v, want none", h.failing)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+)
+
+// htmlReportTemplate is the standalone --html-report page. The map loads
+// Leaflet and OpenStreetMap tiles from their CDNs; everything else is inline.
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{len .Rows}} matching incidents</title>
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css" integrity="sha256-p4NxAoJBhIIN+hmNHrzRCf9tD/miZyoHS5obTRR9BMY=" crossorigin="">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js" integrity="sha256-20nQCchB9co0qIjJZRGuk2/Z9VM+kNiyxNV1lvTlZBo=" crossorigin=""></script>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.4em; margin-bottom: 0.2em; }
+.meta { color: #666; margin-top: 0; }
+#map { height: 420px; margin: 1em 0; border: 1px solid #ccc; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; }
+td.sev { width: 6px; padding: 0; }
+.new { font-weight: bold; }
+</style></head>
+<body>
+<h1>{{len .Rows}} matching incidents</h1>
+<p class="meta">Run at {{.RunAt.Format "Mon Jan 2, 3:04:05 PM MST"}} · {{.Sent}} new alerts sent · filters: {{.Filters}}</p>
+{{if .Points}}<div id="map"></div>{{end}}
+<table>
+<tr><th></th><th>Problem</th><th>Address</th><th>Jurisdiction</th><th>Time</th><th>Alerted</th></tr>
+{{range .Rows}}<tr{{if .New}} class="new"{{end}}><td class="sev" style="background: {{.Color}}"></td><td>{{.Problem}}</td><td>{{.Address}}</td><td>{{.Jurisdiction}}</td><td>{{.Time}}</td><td>{{if .New}}this run{{end}}</td></tr>
+{{else}}<tr><td></td><td colspan="5">No matching incidents.</td></tr>
+{{end}}</table>
+{{if .Points}}<script>
+var points = {{.Points}};
+var map = L.map("map");
+L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+  maxZoom: 19,
+  attribution: "&copy; <a href=\"https://www.openstreetmap.org/copyright\">OpenStreetMap</a> contributors"
+}).addTo(map);
+var bounds = [];
+points.forEach(function (p) {
+  L.circleMarker([p.lat, p.long], {radius: 8, color: p.color, fillOpacity: 0.7})
+    .bindPopup(document.createTextNode(p.label))
+    .addTo(map);
+  bounds.push([p.lat, p.long]);
+});
+map.fitBounds(bounds, {maxZoom: 15, padding: [30, 30]});
+</script>{{end}}
+</body></html>
+`))
+
+// htmlReportPoint is one map marker on the --html-report page.
+type htmlReportPoint struct {
+	Lat   float64 `json:"lat"`
+	Long  float64 `json:"long"`
+	Color string  `json:"color"`
+	Label string  `json:"label"`
+}
+
+// writeHTMLReport writes the run's matching incidents to filename as a
+// standalone page with a table and a map of every incident that may be
+// shown on one. alerted holds the keys alerted this run.
+func writeHTMLReport(filename string, cfg *Config, active []Incident, alerted map[string]bool, sent int) error {
+	type row struct {
+		Problem, Address, Jurisdiction, Time string
+		Color                                template.CSS
+		New                                  bool
+	}
+	data := struct {
+		RunAt   time.Time
+		Sent    int
+		Filters string
+		Rows    []row
+		Points  []htmlReportPoint
+	}{RunAt: time.Now().In(cfg.Timezone), Sent: sent, Filters: cfg.IncidentFilters.String()}
+	for _, incident := range active {
+		shown := incident.Timestamp
+		if at, err := time.Parse(incidentTimeLayout, incident.Timestamp); err == nil {
+			shown = at.In(locationFor(cfg, incident)).Format("Mon Jan 2, 3:04 PM MST")
+		}
+		color := fmt.Sprintf("#%06x", severityColor(severityOf(cfg, incident)))
+		r := row{
+			Problem:      displayProblem(cfg, incident.Problem),
+			Address:      displayAddress(cfg, incident),
+			Jurisdiction: incident.Jurisdiction,
+			Time:         shown,
+			Color:        template.CSS(color),
+			New:          alerted[cfg.KeyFor(incident)],
+		}
+		data.Rows = append(data.Rows, r)
+		if (incident.Lat != 0 || incident.Long != 0) && !mapHidden(cfg, incident) {
+			data.Points = append(data.Points, htmlReportPoint{
+				Lat:   incident.Lat,
+				Long:  incident.Long,
+				Color: color,
+				Label: r.Problem + " at " + r.Address + " (" + shown + ")",
+			})
+		}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := htmlReportTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// newHTTPClient builds the HTTP client shared by the API fetch and webhook sends.
+func newHTTPClient(cfg *Config) *http.Client {
+	return &http.Client{Transport: newTransport(cfg, nil), Timeout: cfg.HTTPTimeout}
+}
+
+// newAPIClient builds the client used for the feed fetch. It differs from the
+// shared client only in BIND_ADDR and INSECURE_SKIP_VERIFY, so webhook sends
+// keep the default source address and always verify certificates.
+func newAPIClient(cfg *Config) *http.Client {
+	var localAddr net.Addr
+	if cfg.BindAddr != nil {
+		localAddr = &net.TCPAddr{IP: cfg.BindAddr}
+	}
+	transport := newTransport(cfg, localAddr)
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.HTTPTimeout}
+}
+
+// newTransport clones the default transport and swaps in a dialer honouring
+// DNS_RESOLVER, IP_PREFERENCE and the per-phase timeouts. A non-nil
+// localAddr binds outgoing connections to that source address.
+func newTransport(cfg *Config, localAddr net.Addr) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+		LocalAddr: localAddr,
+	}
+	if cfg.DNSResolver != "" {
+		dialer.Resolver = newTransportResolver(cfg)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	if cfg.IPPreference != "" {
+		transport.DialContext = preferFamilyDialer(dialer, cfg.IPPreference)
+	}
+	return transport
+}
+
+// newTransportResolver returns a resolver that sends every lookup to DNS_RESOLVER.
+func newTransportResolver(cfg *Config) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, cfg.DNSResolver)
+		},
+	}
+}
+
+// preferFamilyDialer resolves the host itself and dials addresses of the
+// preferred family ("ipv4" or "ipv6") first, falling back to the others.
+func preferFamilyDialer(dialer *net.Dialer, family string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	resolver := dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(ips, func(i, j int) bool {
+			return isIPFamily(ips[i].IP, family) && !isIPFamily(ips[j].IP, family)
+		})
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// isIPFamily reports whether ip belongs to the named family.
+func isIPFamily(ip net.IP, family string) bool {
+	if family == "ipv4" {
+		return ip.To4() != nil
+	}
+	return ip.To4() == nil
+}
+
+// localIP parses BIND_ADDR and checks that it is assigned to this host.
+func localIP(value string) (net.IP, error) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not an IP address", value)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not assigned to any local interface", ip)
+}
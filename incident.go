@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Incident struct matches the JSON object structure from the API.
+type Incident struct {
+	ID           string  `json:"id,omitempty"`
+	Jurisdiction string  `json:"jurisdiction"`
+	Problem      string  `json:"problem"`
+	Address      string  `json:"address"`
+	Lat          float64 `json:"lat"`
+	Long         float64 `json:"long"`
+	Timestamp    string  `json:"timestamp"`
+	// MediaURL is an optional camera snapshot or other image for the incident.
+	MediaURL string `json:"media_url,omitempty"`
+	// Units are the dispatched unit IDs, when the feed reports them.
+	Units []string `json:"units,omitempty"`
+	// Type and Modifiers are the problem split on PROBLEM_DELIMITER, e.g.
+	// "MVC" and ["INJURY", "BLOCKING"]. They are filled after decoding.
+	Type      string   `json:"problem_type,omitempty"`
+	Modifiers []string `json:"problem_modifiers,omitempty"`
+}
+
+// UnmarshalJSON decodes an incident, accepting lat/long as either JSON
+// numbers or quoted strings since some feed variants send the latter. The
+// optional id may likewise be a number or a string, and units either a list
+// or a single comma-separated string.
+func (i *Incident) UnmarshalJSON(data []byte) error {
+	type plainIncident Incident
+	aux := struct {
+		*plainIncident
+		ID    json.RawMessage `json:"id"`
+		Lat   flexFloat       `json:"lat"`
+		Long  flexFloat       `json:"long"`
+		Units json.RawMessage `json:"units"`
+	}{plainIncident: (*plainIncident)(i)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	i.Lat, i.Long = float64(aux.Lat), float64(aux.Long)
+	i.ID = ""
+	if len(aux.ID) > 0 && !bytes.Equal(aux.ID, []byte("null")) {
+		var s string
+		if json.Unmarshal(aux.ID, &s) == nil {
+			i.ID = s
+		} else {
+			i.ID = string(bytes.TrimSpace(aux.ID))
+		}
+	}
+	units, err := parseUnits(aux.Units)
+	if err != nil {
+		return err
+	}
+	i.Units = units
+	return nil
+}
+
+// parseUnits decodes a units value: a JSON list of unit IDs, a string such
+// as "E5, M12", or null.
+func parseUnits(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '[' {
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("invalid units: %w", err)
+		}
+		var units []string
+		for _, unit := range list {
+			if unit = strings.TrimSpace(unit); unit != "" {
+				units = append(units, unit)
+			}
+		}
+		return units, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid units: %w", err)
+	}
+	return splitList(s), nil
+}
+
+// splitProblems fills Type and Modifiers from each problem. A problem that
+// does not split into at least two parts is all type, with no modifiers.
+func splitProblems(delimiter string, incidents []Incident) {
+	for i := range incidents {
+		var parts []string
+		for _, part := range strings.Split(incidents[i].Problem, delimiter) {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) < 2 {
+			incidents[i].Type, incidents[i].Modifiers = strings.TrimSpace(incidents[i].Problem), nil
+			continue
+		}
+		incidents[i].Type, incidents[i].Modifiers = parts[0], parts[1:]
+	}
+}
+
+// flexFloat is a float64 that also decodes from a quoted string. An empty
+// string or null decodes as zero.
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*f = 0
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			*f = 0
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid coordinate %q", s)
+		}
+		*f = flexFloat(v)
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = flexFloat(v)
+	return nil
+}
+
+// safeMediaURL returns the incident's media URL if it is an absolute http(s)
+// URL, or "" so that anything else is never embedded.
+func safeMediaURL(incident Incident) string {
+	u, err := url.Parse(strings.TrimSpace(incident.MediaURL))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
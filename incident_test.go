@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFlexFloat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{`35.7796`, 35.7796, false},
+		{`-78.6382`, -78.6382, false},
+		{`"35.7796"`, 35.7796, false},
+		{`" -78.6382 "`, -78.6382, false},
+		{`""`, 0, false},
+		{`null`, 0, false},
+		{`"n/a"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var f flexFloat
+		err := json.Unmarshal([]byte(tt.in), &f)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && float64(f) != tt.want {
+			t.Errorf("%s = %v, want %v", tt.in, float64(f), tt.want)
+		}
+	}
+}
+
+func TestIncidentUnmarshalCoordinates(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		lat, long float64
+	}{
+		{"numbers", `{"problem":"MVC PI","lat":35.78,"long":-78.64}`, 35.78, -78.64},
+		{"strings", `{"problem":"MVC PI","lat":"35.78","long":"-78.64"}`, 35.78, -78.64},
+		{"empty strings", `{"problem":"MVC PI","lat":"","long":""}`, 0, 0},
+		{"missing", `{"problem":"MVC PI"}`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var i Incident
+			if err := json.Unmarshal([]byte(tt.in), &i); err != nil {
+				t.Fatal(err)
+			}
+			if i.Problem != "MVC PI" || i.Lat != tt.lat || i.Long != tt.long {
+				t.Errorf("got %+v, want lat %v long %v", i, tt.lat, tt.long)
+			}
+		})
+	}
+}
+
+func TestIncidentsMixedCoordinateTypes(t *testing.T) {
+	var incidents []Incident
+	body := `[{"problem":"A","lat":35.1,"long":-78.1},{"problem":"B","lat":"35.2","long":"-78.2"}]`
+	if err := json.Unmarshal([]byte(body), &incidents); err != nil {
+		t.Fatalf("one string-typed record failed the array: %s", err)
+	}
+	if len(incidents) != 2 || incidents[1].Lat != 35.2 {
+		t.Errorf("got %+v", incidents)
+	}
+}
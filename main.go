@@ -3,46 +3,62 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv" // Library to read .env files
 )
 
-// Incident struct matches the JSON object structure from the API.
-type Incident struct {
-	Jurisdiction string  `json:"jurisdiction"`
-	Problem      string  `json:"problem"`
-	Address      string  `json:"address"`
-	Lat          float64 `json:"lat"`
-	Long         float64 `json:"long"`
-	Timestamp    string  `json:"timestamp"`
-}
+// incidentTimeLayout is the format of Incident.Timestamp in the API response.
+const incidentTimeLayout = "2006-01-02 15:04:05.000"
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
 
 // Structs for creating a rich Discord Embed, now with Thumbnail support
 type DiscordWebhookPayload struct {
-	Username string         `json:"username"`
-	Embeds   []DiscordEmbed `json:"embeds"`
+	Username        string           `json:"username"`
+	Content         string           `json:"content,omitempty"`
+	Embeds          []DiscordEmbed   `json:"embeds,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+	// ThreadName makes a forum channel webhook open a new post.
+	ThreadName string `json:"thread_name,omitempty"`
+}
+
+// AllowedMentions limits which mentions in Content actually ping.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
 }
 
 type DiscordEmbed struct {
-	Title     string         `json:"title"`
-	Color     int            `json:"color"`
-	Fields    []EmbedField   `json:"fields"`
-	Footer    EmbedFooter    `json:"footer"`
-	Timestamp string         `json:"timestamp"`
-	Thumbnail EmbedThumbnail `json:"thumbnail,omitempty"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []EmbedField   `json:"fields,omitempty"`
+	Footer      EmbedFooter    `json:"footer"`
+	Timestamp   string         `json:"timestamp"`
+	Thumbnail   EmbedThumbnail `json:"thumbnail,omitempty"`
+	Image       *EmbedImage    `json:"image,omitempty"`
 }
 
 type EmbedThumbnail struct {
 	URL string `json:"url"`
 }
 
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
 type EmbedField struct {
 	Name   string `json:"name"`
 	Value  string `json:"value"`
@@ -53,153 +69,1305 @@ type EmbedFooter struct {
 	Text string `json:"text"`
 }
 
-// loadSentIncidents reads the JSON file of sent alert IDs into a map.
-func loadSentIncidents(filename string) (map[string]bool, error) {
-	sentIDs := make(map[string]bool)
-	data, err := os.ReadFile(filename)
-	if os.IsNotExist(err) {
-		return sentIDs, nil
-	} else if err != nil {
-		return nil, err
+// DiscordMessage is the subset of the message object Discord returns for ?wait=true.
+type DiscordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id,omitempty"`
+}
+
+// embedFieldBuilders maps each EMBED_FIELDS name to the function that renders it.
+var embedFieldBuilders = map[string]func(cfg *Config, alert pendingAlert) EmbedField{
+	"address": func(cfg *Config, alert pendingAlert) EmbedField {
+		return EmbedField{Name: "Address", Value: displayAddress(cfg, alert.incident)}
+	},
+	"jurisdiction": func(_ *Config, alert pendingAlert) EmbedField {
+		return EmbedField{Name: "Jurisdiction", Value: alert.incident.Jurisdiction}
+	},
+	"problem": func(cfg *Config, alert pendingAlert) EmbedField {
+		return EmbedField{Name: "Problem", Value: displayProblem(cfg, alert.incident.Problem)}
+	},
+	"time": func(_ *Config, alert pendingAlert) EmbedField {
+		return EmbedField{Name: "Time", Value: alert.parsedTime.Format("Mon Jan 2, 3:04 PM MST")}
+	},
+	"coordinates": func(cfg *Config, alert pendingAlert) EmbedField {
+		if mapHidden(cfg, alert.incident) {
+			return EmbedField{Name: "Coordinates", Value: "Withheld"}
+		}
+		return EmbedField{Name: "Coordinates", Value: formatCoords(cfg, alert.incident.Lat, alert.incident.Long)}
+	},
+	"detected": func(_ *Config, alert pendingAlert) EmbedField {
+		detected := alert.firstSeen.In(alert.parsedTime.Location())
+		value := detected.Format("3:04:05 PM MST")
+		if alert.timeParsed {
+			value += fmt.Sprintf(" (%s after dispatch)", max(detected.Sub(alert.parsedTime), 0).Round(time.Second))
+		}
+		return EmbedField{Name: "Detected", Value: value}
+	},
+}
+
+// buildEmbedFields renders an alert's fields at a verbosity: the
+// EMBED_FIELDS entries for it, in order, then its notes. All fields are
+// single-column for mobile readability.
+func buildEmbedFields(cfg *Config, alert pendingAlert, verbosity Verbosity) []EmbedField {
+	names := embedFieldNames(cfg, verbosity)
+	fields := make([]EmbedField, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, embedFieldBuilders[name](cfg, alert))
 	}
-	if len(data) == 0 {
-		return sentIDs, nil
+	return append(fields, alertNotes(cfg, alert, verbosity)...)
+}
+
+// fetchAllIncidents downloads and decodes the current incident list from the
+// API, or reads it from disk when RWECC_URL is a file.
+func fetchAllIncidents(client *http.Client, cfg *Config) ([]Incident, error) {
+	var body []byte
+	if path, ok := feedPath(cfg.APIURL); ok {
+		var err error
+		if body, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading feed file: %w", err)
+		}
+	} else {
+		var err error
+		if body, err = fetchFeedBody(client, cfg); err != nil {
+			return nil, err
+		}
 	}
-	err = json.Unmarshal(data, &sentIDs)
-	return sentIDs, err
+
+	incidents, err := decodeIncidents(cfg, body)
+	if err != nil {
+		return nil, &feedParseError{err}
+	}
+	return incidents, nil
 }
 
-// saveSentIncidents writes the updated map of sent alert IDs back to the file.
-func saveSentIncidents(filename string, sentIDs map[string]bool) error {
-	data, err := json.MarshalIndent(sentIDs, "", "  ")
+// feedPath returns the local path when RWECC_URL is a file:// URL or a plain
+// path rather than an HTTP endpoint.
+func feedPath(apiURL string) (string, bool) {
+	if strings.HasPrefix(apiURL, "file:") {
+		u, err := url.Parse(apiURL)
+		if err != nil {
+			return "", false
+		}
+		if u.Opaque != "" {
+			return u.Opaque, true
+		}
+		return u.Path, true
+	}
+	return apiURL, !strings.Contains(apiURL, "://")
+}
+
+// fetchFeedBody makes the API request and returns the response body.
+func fetchFeedBody(client *http.Client, cfg *Config) ([]byte, error) {
+	var reqBody io.Reader
+	if cfg.APIBody != "" {
+		reqBody = strings.NewReader(cfg.APIBody)
+	}
+	req, err := http.NewRequest(cfg.APIMethod, cfg.APIURL, reqBody)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("building API request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", cfg.APIContentType)
+	}
+	req.Header.Set("X-Request-ID", traceID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching data from API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading API response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &feedError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
-	return os.WriteFile(filename, data, 0644)
+	return body, nil
 }
 
-// sendToDiscord sends a rich embed for a new MVC incident.
-func sendToDiscord(webhookURL string, incident Incident, parsedTime time.Time, mapsAPIKey string) {
-	// Determine embed color based on the problem description.
-	var color int
-	problemLower := strings.ToLower(incident.Problem)
-	if strings.Contains(problemLower, "injur") {
-		color = 15158332 // Red for injuries
-	} else if strings.Contains(problemLower, "damage") || strings.Contains(problemLower, "hit & run") {
-		color = 15844367 // Yellow for damage/hit & run
+// feedError is a non-2xx feed response.
+type feedError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *feedError) Error() string {
+	return fmt.Sprintf("API returned non-2xx status: %s", e.Status)
+}
+
+// feedParseError is a feed response that arrived but could not be used.
+type feedParseError struct {
+	err error
+}
+
+func (e *feedParseError) Error() string { return e.err.Error() }
+func (e *feedParseError) Unwrap() error { return e.err }
+
+// rateLimited reports whether the feed asked us to back off.
+func (e *feedError) rateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// feedRetryAfter returns the Retry-After of a rate-limited fetch error, or zero.
+func feedRetryAfter(err error) time.Duration {
+	var feedErr *feedError
+	if errors.As(err, &feedErr) && feedErr.rateLimited() {
+		return feedErr.RetryAfter
+	}
+	return 0
+}
+
+// fetchWithRetry is fetchAllIncidents for one-shot runs: a rate-limited
+// fetch is retried up to FETCH_RETRIES times, waiting for Retry-After or,
+// without one, an exponential backoff from WEBHOOK_RETRY_BACKOFF. Retries
+// are counted in errs.
+func fetchWithRetry(client *http.Client, cfg *Config, errs errorSummary) ([]Incident, error) {
+	backoff := cfg.WebhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		incidents, err := fetchAllIncidents(client, cfg)
+		var feedErr *feedError
+		if err == nil || !errors.As(err, &feedErr) || !feedErr.rateLimited() {
+			return incidents, err
+		}
+		if attempt >= cfg.FetchRetries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		wait := backoff
+		if feedErr.RetryAfter > 0 {
+			wait = feedErr.RetryAfter
+		}
+		log.Printf("Feed is rate limiting (%s), retrying in %s", err, wait)
+		errs.add(errFetchRetry)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// decodeIncidents parses a feed response in the configured API_FORMAT. Flat
+// responses have FIELD_MAP applied and, with VALIDATE_SCHEMA, are checked
+// before decoding. Coordinates are reprojected to WGS84 when COORD_CRS is set.
+func decodeIncidents(cfg *Config, body []byte) ([]Incident, error) {
+	var incidents []Incident
+	if cfg.APIFormat == "arcgis" {
+		var err error
+		if incidents, err = decodeArcGISIncidents(body, cfg.ArcGISFields); err != nil {
+			return nil, fmt.Errorf("decoding ArcGIS response: %w", err)
+		}
 	} else {
-		color = 3447003 // Default blue for everything else
+		if len(cfg.FieldMap) > 0 {
+			var err error
+			if body, err = remapFields(body, cfg.FieldMap); err != nil {
+				return nil, fmt.Errorf("applying FIELD_MAP: %w", err)
+			}
+		}
+		if cfg.ValidateSchema {
+			if problems := validateIncidentsJSON(body); len(problems) > 0 {
+				for i, problem := range problems {
+					if i == maxSchemaProblems {
+						log.Printf("Schema problem: ...and %d more", len(problems)-i)
+						break
+					}
+					log.Printf("Schema problem: %s", problem)
+				}
+				return nil, fmt.Errorf("validating API response: %d schema problems, skipping this cycle", len(problems))
+			}
+		}
+		if err := json.Unmarshal(body, &incidents); err != nil {
+			return nil, fmt.Errorf("unmarshalling JSON: %w", err)
+		}
+	}
+	if cfg.CoordCRS != nil {
+		reprojectIncidents(cfg.CoordCRS, incidents)
 	}
+	if cfg.ProblemDelimiter != "" {
+		splitProblems(cfg.ProblemDelimiter, incidents)
+	}
+	return incidents, nil
+}
+
+// footerText is the embed footer, with the build version appended when FOOTER_VERSION is set.
+func footerText(cfg *Config) string {
+	footer := "Fetched from Raleigh-Wake ECC"
+	if cfg.FooterVersion {
+		footer += " • " + version
+	}
+	return footer
+}
 
-	// All fields are now single-column for mobile readability.
-	fields := []EmbedField{
-		{Name: "Address", Value: incident.Address, Inline: false},
-		{Name: "Jurisdiction", Value: incident.Jurisdiction, Inline: false},
+// withWait adds wait=true to a webhook URL so Discord returns the created message.
+func withWait(webhookURL string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("wait", "true")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// postContent posts a plain text message to a Discord webhook.
+func postContent(client *http.Client, webhookURL, content string) error {
+	payload, err := json.Marshal(DiscordWebhookPayload{Username: "RWECC MVC Bot", Content: content})
+	if err != nil {
+		return fmt.Errorf("creating JSON payload: %w", err)
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned non-2xx status: %s", resp.Status)
 	}
+	return nil
+}
 
+// sendToDiscord sends a rich embed for a new incident and returns the posted message.
+func sendToDiscord(client *http.Client, cfg *Config, webhookURL string, alert pendingAlert) (DiscordMessage, error) {
+	incident, parsedTime := alert.incident, alert.parsedTime
 	embed := DiscordEmbed{
-		Title:     incident.Problem,
-		Color:     color,
-		Fields:    fields,
-		Footer:    EmbedFooter{Text: "Fetched from Raleigh-Wake ECC"},
+		Title:     displayProblem(cfg, incident.Problem),
+		Color:     severityColor(severityOf(cfg, incident)),
+		Fields:    buildEmbedFields(cfg, alert, cfg.DiscordVerbosity),
+		Footer:    EmbedFooter{Text: footerText(cfg)},
 		Timestamp: parsedTime.Format(time.RFC3339),
 	}
+	if alert.sequence > 0 {
+		label := "Alert"
+		if alert.previousProblem != "" {
+			label = "Update to alert"
+		}
+		embed.Footer.Text = fmt.Sprintf("%s #%d • %s", label, alert.sequence, embed.Footer.Text)
+	}
+	if cfg.AckPrompt {
+		embed.Description = ackPrompt
+	}
+	if !alert.replayOf.IsZero() {
+		embed.Title = "[Replay] " + embed.Title
+		embed.Description = "Originally sent " + alert.replayOf.In(cfg.Timezone).Format("Mon Jan 2, 3:04 PM MST")
+	}
+	if alert.previousProblem != "" {
+		embed.Title = "Update: " + embed.Title
+		embed.Description = "Previously " + displayProblem(cfg, alert.previousProblem)
+		if alert.originalURL != "" {
+			embed.Description += fmt.Sprintf(" · [original alert](%s)", alert.originalURL)
+		}
+		embed.Color = cfg.ColorUpdate
+	}
 
 	// Generate and add the static map thumbnail if an API key is provided.
-	if mapsAPIKey != "" {
-		mapURL := fmt.Sprintf(
-			"https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=14&size=300x300&markers=color:red%%7C%.6f,%.6f&key=%s",
-			incident.Lat, incident.Long, incident.Lat, incident.Long, mapsAPIKey,
-		)
+	// Redacted and NO_MAP_FOR incidents get no map, since the marker would
+	// give the location away; MAP_FOR_JURISDICTIONS saves quota elsewhere.
+	// Coarse coordinates get a note instead (see alertNotes).
+	if cfg.MapsAPIKey != "" && !mapHidden(cfg, incident) && !coordsCoarse(cfg, incident) && thumbnailWanted(cfg, incident) {
+		mapURL := buildMapURL([]LatLng{{Lat: incident.Lat, Long: incident.Long}}, cfg.MapsAPIKey, cfg.MapType)
 		embed.Thumbnail = EmbedThumbnail{URL: mapURL}
 	}
 
+	if mediaURL := safeMediaURL(incident); mediaURL != "" {
+		embed.Image = &EmbedImage{URL: mediaURL}
+	} else if incident.MediaURL != "" {
+		log.Printf("Ignoring media URL for %q with unsupported scheme: %q", alert.key, incident.MediaURL)
+	}
+
 	payload := DiscordWebhookPayload{
 		Username: "RWECC MVC Bot",
 		Embeds:   []DiscordEmbed{embed},
 	}
+	// Without a bot to start a thread afterwards, forum webhooks can open
+	// one themselves.
+	if cfg.ThreadNameTemplate != nil && cfg.DiscordBotToken == "" && alert.previousProblem == "" && alert.replayOf.IsZero() {
+		payload.ThreadName = threadName(cfg, alert)
+	}
+	// Updates and replays never ping; only the first alert for an incident does.
+	if roles := mentionsFor(cfg, incident); len(roles) > 0 && alert.previousProblem == "" && alert.replayOf.IsZero() {
+		mentions := make([]string, len(roles))
+		for i, role := range roles {
+			mentions[i] = "<@&" + role + ">"
+		}
+		payload.Content = strings.Join(mentions, " ")
+		payload.AllowedMentions = &AllowedMentions{Parse: []string{}, Roles: roles}
+	}
 
+	if fitPayload(&payload) {
+		log.Printf("Warning: trimmed alert %q to fit Discord's message size limits", alert.key)
+	}
+
+	var msg DiscordMessage
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error creating JSON payload: %s", err)
-		return
+		return msg, fmt.Errorf("creating JSON payload: %w", err)
 	}
 
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonPayload))
+	postURL, err := withWait(webhookURL)
 	if err != nil {
-		log.Printf("Error sending to Discord: %s", err)
-		return
+		return msg, fmt.Errorf("parsing webhook URL: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		log.Printf("Discord returned non-2xx status: %s", resp.Status)
+	body, err := postWebhook(client, cfg, postURL, jsonPayload, nil)
+	if err != nil {
+		return msg, err
 	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return msg, fmt.Errorf("decoding Discord response: %w", err)
+	}
+	return msg, nil
+}
+
+// App bundles the configuration and long-lived state shared across fetch cycles.
+type App struct {
+	cfg       *Config
+	client    *http.Client
+	apiClient *http.Client
+	store     StateStore
+	counter   *incidentCounter
+	anomaly   *anomalyState
+	tally     *typeTally
+	geocoder  *geocoder
+	tts       *ttsClient
+	weather   *weatherClient
+	routing   *routingClient
+	external  *externalDedupClient
+	notifiers []Notifier
+	filters   []IncidentFilter
+	metrics   *metrics
+	acks      *ackTracker
+	// errs counts the current cycle's errors for the closing summary.
+	errs errorSummary
+	// staleBefore, set by --since on a first run, marks older incidents as
+	// sent without alerting. It is cleared after the first cycle.
+	staleBefore time.Time
+	// sequences holds the alert numbers taken by alerts not yet delivered.
+	sequences map[string]int64
+	// anomalyCounted holds the pending keys already counted towards the
+	// anomaly rate, so an alert retried after a failed delivery counts once.
+	anomalyCounted map[string]bool
+	// tracked remembers each alerted incident's problem for UPDATE_ALERTS.
+	tracked map[string]*trackedIncident
+	// stopping is closed when a daemon is asked to shut down; see
+	// drainOnSignal.
+	stopping chan struct{}
+	// configSource is the --config file or URL, re-read into cfg.Policy on
+	// SIGHUP and every CONFIG_REFRESH_INTERVAL. envFilters is
+	// INCIDENT_FILTERS, for when a reloaded policy drops its filters.
+	configSource string
+	envFilters   filterExpr
+	reloads      chan os.Signal
+	// htmlReport, from --html-report, is rewritten with the matching
+	// incidents after every cycle.
+	htmlReport string
+	// ndjsonOnly skips the Discord routes and other notifiers, leaving
+	// --emit-ndjson output as the only delivery.
+	ndjsonOnly bool
+	// droppedLogged holds the keys whose drop reason has already been logged,
+	// so a daemon does not repeat the same line every cycle.
+	droppedLogged map[string]bool
 }
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Note: .env file not found, reading credentials from environment")
+	showVersion := flag.Bool("version", false, "print the build version and exit")
+	pruneState := flag.Duration("prune-state", 0, "remove state entries older than this duration (e.g. 72h) and exit")
+	dashboard := flag.String("dashboard", "", "serve the metrics and dashboard from SNAPSHOT_FILE on this address without fetching")
+	testFilter := flag.String("test-filter", "", "print which incidents in this saved feed file match the filters, then exit without sending")
+	since := flag.Duration("since", 0, "on a first run with empty state, mark incidents older than this (e.g. 2h) as sent without alerting")
+	assumeYes := flag.Bool("yes", false, "skip the confirmation prompt for destructive state operations")
+	ignore := flag.String("ignore", "", "add an incident key to the ignore set so it is never alerted, then exit")
+	serveMock := flag.String("serve-mock", "", "serve a mock incident feed on this address (e.g. 127.0.0.1:8089) instead of running")
+	mockFile := flag.String("mock-file", "mock_incidents.json", "incident templates served by --serve-mock")
+	emitNDJSON := flag.Bool("emit-ndjson", false, "also write each new alert to stdout as a JSON line")
+	ndjsonOnly := flag.Bool("ndjson-only", false, "write alerts to stdout as JSON lines instead of sending webhooks")
+	diff := flag.Bool("diff", false, "compare two saved feed files (--diff A.json B.json) and report incidents that appeared, disappeared or changed, then exit")
+	digest := flag.Bool("digest", false, "post a digest of the last DIGEST_WINDOW of ARCHIVE_FILE now, then exit")
+	replayLast := flag.Int("replay-last", 0, "re-post the last N alerts from ARCHIVE_FILE, oldest first and marked [Replay], without touching state, then exit")
+	htmlReport := flag.String("html-report", "", "write each run's matching incidents to this file as a standalone HTML page with a map")
+	report := flag.Bool("report", false, "print incident counts by type per day from STATS_FILE (--report [FROM [TO]], dates as YYYY-MM-DD; default the last 7 days), then exit")
+	configFile := flag.String("config", "", "YAML file or http(s) URL mapping each severity to webhooks and mention roles, optionally with filters")
+	envFile := flag.String("env-file", "", "load environment variables from this file instead of .env")
+	noEnvFile := flag.Bool("no-env-file", false, "do not load any .env file; read configuration from the environment only")
+	flag.Parse()
+	setLogTrace("")
+	if *showVersion {
+		fmt.Println(version)
+		return
+	}
+	if *serveMock != "" {
+		log.Fatal(serveMockFeed(*serveMock, *mockFile))
+	}
+
+	switch {
+	case *noEnvFile && *envFile != "":
+		log.Fatalf("Error: --env-file and --no-env-file cannot be combined")
+	case *envFile != "":
+		if err := godotenv.Load(*envFile); err != nil {
+			log.Fatalf("Error: loading --env-file: %s", err)
+		}
+	case !*noEnvFile:
+		if err := godotenv.Load(); os.IsNotExist(err) {
+			log.Println("Note: .env file not found, reading credentials from environment")
+		} else if err != nil {
+			log.Printf("Warning: ignoring .env: %s", err)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Error: %s", err)
+	}
+	envFilters := cfg.IncidentFilters
+	if *configFile != "" {
+		if len(cfg.Routes) > 0 {
+			log.Fatalf("Error: ROUTES cannot be combined with --config; move the routes into its severities block")
+		}
+		policy, err := loadPolicySource(newHTTPClient(cfg), cfg, *configFile, true)
+		if err != nil {
+			log.Fatalf("Error loading --config: %s", err)
+		}
+		applyPolicy(cfg, policy, envFilters)
+	} else if cfg.ConfigRefreshInterval > 0 {
+		log.Println("Warning: CONFIG_REFRESH_INTERVAL is only used with --config")
+	}
+
+	if *dashboard != "" {
+		if cfg.SnapshotFilename == "" {
+			log.Fatalf("Error: --dashboard needs SNAPSHOT_FILE, written by the fetching process")
+		}
+		serveMetrics(*dashboard, cfg, &metrics{snapshotFile: cfg.SnapshotFilename})
+		return
+	}
+
+	if *report {
+		if cfg.StatsFilename == "" {
+			log.Fatalf("Error: --report needs STATS_FILE")
+		}
+		tally, err := loadTally(cfg.StatsFilename)
+		if err != nil {
+			log.Fatalf("Error loading stats: %s", err)
+		}
+		from, to, err := parseReportRange(flag.Args(), cfg.Timezone)
+		if err != nil {
+			log.Fatalf("Error: --report: %s", err)
+		}
+		if err := writeReport(os.Stdout, tally, from, to); err != nil {
+			log.Fatalf("Error writing report: %s", err)
+		}
+		return
+	}
+
+	if *replayLast != 0 {
+		if cfg.ArchiveFilename == "" {
+			log.Fatalf("Error: --replay-last needs ARCHIVE_FILE")
+		}
+		if *replayLast < 0 {
+			log.Fatalf("Error: --replay-last must be positive")
+		}
+		if err := replayLastAlerts(newHTTPClient(cfg), cfg, *replayLast); err != nil {
+			log.Fatalf("Error replaying alerts: %s", err)
+		}
+		return
 	}
 
-	apiURL := os.Getenv("RWECC_URL")
-	webhookURL := os.Getenv("RWECC_DISCORD_HOOK")
-	mapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY") // Load the new API key
-	stateFilename := "sent_rwecc_incidents.json"
+	if *digest {
+		if cfg.ArchiveFilename == "" {
+			log.Fatalf("Error: --digest needs ARCHIVE_FILE")
+		}
+		if err := postDigest(newHTTPClient(cfg), cfg); err != nil {
+			log.Fatalf("Error posting digest: %s", err)
+		}
+		return
+	}
+
+	if *diff {
+		if flag.NArg() != 2 {
+			log.Fatalf("Error: --diff needs two feed files, e.g. --diff A.json B.json")
+		}
+		if err := diffSnapshots(cfg, flag.Arg(0), flag.Arg(1)); err != nil {
+			log.Fatalf("Error diffing snapshots: %s", err)
+		}
+		return
+	}
 
-	if apiURL == "" || webhookURL == "" {
-		log.Fatalln("Error: RWECC_URL and RWECC_DISCORD_HOOK must be set in your environment or .env file.")
+	if *testFilter != "" {
+		if err := testFilters(cfg, *testFilter); err != nil {
+			log.Fatalf("Error testing filters: %s", err)
+		}
+		return
 	}
 
-	sentIncidents, err := loadSentIncidents(stateFilename)
+	store, err := newStateStore(cfg)
 	if err != nil {
 		log.Fatalf("Error loading sent incidents: %s", err)
 	}
+	defer store.Close()
 
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		log.Fatalf("Error fetching data from API: %s", err)
+	if *ignore != "" {
+		if err := store.Mark(ignoreKey(*ignore)); err != nil {
+			log.Fatalf("Error adding %q to the ignore set: %s", *ignore, err)
+		}
+		if err := store.Save(); err != nil {
+			log.Fatalf("Error saving sent incidents file: %s", err)
+		}
+		log.Printf("Ignoring %q; it will not be alerted.", *ignore)
+		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if *pruneState > 0 {
+		count, err := store.CountOlderThan(*pruneState)
+		if err != nil {
+			log.Fatalf("Error counting state entries: %s", err)
+		}
+		if count == 0 {
+			log.Printf("No state entries older than %s.", *pruneState)
+			return
+		}
+		ok, err := confirm(fmt.Sprintf("Will remove %d state entries older than %s; their incidents may alert again", count, *pruneState), *assumeYes)
+		if err != nil {
+			log.Fatalf("Error: %s", err)
+		}
+		if !ok {
+			log.Println("Aborted; state left unchanged.")
+			return
+		}
+		removed, err := store.Prune(*pruneState)
+		if err != nil {
+			log.Fatalf("Error pruning state: %s", err)
+		}
+		if err := store.Save(); err != nil {
+			log.Fatalf("Error saving sent incidents file: %s", err)
+		}
+		log.Printf("Removed %d state entries older than %s.", removed, *pruneState)
+		return
+	}
+
+	app := &App{
+		cfg:            cfg,
+		client:         newHTTPClient(cfg),
+		apiClient:      newAPIClient(cfg),
+		store:          store,
+		filters:        buildFilters(cfg),
+		sequences:      make(map[string]int64),
+		anomalyCounted: make(map[string]bool),
+	}
+
+	if cfg.GeocoderURL != "" {
+		app.geocoder = newGeocoder(app.client, cfg)
+	}
+	if cfg.TTSURL != "" {
+		app.tts = newTTSClient(app.client, cfg.TTSURL)
+	}
+	if cfg.ExternalDedupURL != "" {
+		app.external = newExternalDedupClient(app.client, cfg.ExternalDedupURL, cfg.ExternalDedupCacheTTL)
+	}
+	if cfg.RoutingAPIURL != "" {
+		app.routing = newRoutingClient(app.client, cfg.RoutingAPIURL, cfg.RoutingStation)
+	}
+	if cfg.WeatherAPIURL != "" {
+		app.weather = newWeatherClient(app.client, cfg.WeatherAPIURL, cfg.WeatherAPIKey)
+	}
+	if cfg.GenericWebhookURL != "" {
+		notifier, err := newGenericNotifier(app.client, cfg)
+		if err != nil {
+			log.Fatalf("Error: %s", err)
+		}
+		app.notifiers = append(app.notifiers, notifier)
+	}
+	if cfg.MatrixHomeserver != "" {
+		app.notifiers = append(app.notifiers, newMatrixNotifier(app.client, cfg))
+	}
+	if cfg.MQTTBroker != "" {
+		app.notifiers = append(app.notifiers, newMQTTNotifier(cfg))
+	}
+	if cfg.SNSTopicARN != "" {
+		notifier, err := newSNSNotifier(cfg)
+		if err != nil {
+			log.Fatalf("Error: %s", err)
+		}
+		app.notifiers = append(app.notifiers, notifier)
+	}
+	app.htmlReport = *htmlReport
+	app.configSource, app.envFilters = *configFile, envFilters
+	if *ndjsonOnly {
+		app.ndjsonOnly = true
+		app.notifiers = []Notifier{newNDJSONNotifier(os.Stdout, cfg)}
+	} else if *emitNDJSON {
+		app.notifiers = append(app.notifiers, newNDJSONNotifier(os.Stdout, cfg))
+	}
+
+	if cfg.UpdateAlerts {
+		if app.tracked, err = loadTrackedIncidents(cfg.UpdateStateFilename); err != nil {
+			log.Fatalf("Error loading update state: %s", err)
+		}
+	}
+
+	if cfg.StatsFilename != "" {
+		if app.tally, err = loadTally(cfg.StatsFilename); err != nil {
+			log.Fatalf("Error loading stats: %s", err)
+		}
+	}
+
+	if cfg.AnomalyFactor > 0 {
+		if app.anomaly, err = loadAnomalyState(cfg.AnomalyStateFilename); err != nil {
+			log.Fatalf("Error loading anomaly state: %s", err)
+		}
+	}
+
+	if *since > 0 {
+		// Every entry was marked before now, so this counts the whole store.
+		entries, err := store.CountOlderThan(0)
+		if err != nil {
+			log.Fatalf("Error checking state: %s", err)
+		}
+		if entries == 0 {
+			app.staleBefore = time.Now().Add(-*since)
+			log.Printf("First run with empty state: incidents older than %s will be marked as sent without alerting.", *since)
+		} else {
+			log.Printf("Note: state already has %d entries, so --since is ignored.", entries)
+		}
+	}
+
+	if cfg.PushgatewayURL != "" {
+		app.metrics = &metrics{}
+	}
+
+	if !daemonMode(cfg) {
+		sent, err := app.runCycle()
+		if err != nil {
+			log.Printf("Error %s", err)
+		}
+		app.errs.report("run")
+		app.pushMetrics()
+		if err != nil {
+			os.Exit(1)
+		}
+		if sent == 0 && cfg.ExitCodeOnNoNew != 0 {
+			store.Close()
+			os.Exit(cfg.ExitCodeOnNoNew)
+		}
+		return
+	}
+
+	if cfg.StatsWebhookURL != "" {
+		app.counter = newIncidentCounter(cfg.StatsInterval)
+	}
+	nextStats := time.Now().Add(cfg.StatsInterval)
+	var health *webhookHealth
+	nextHealthcheck := time.Now()
+	if cfg.WebhookHealthInterval > 0 {
+		health = newWebhookHealth(app.client, cfg)
+	}
+	var nextDigest time.Time
+	if cfg.DigestAt != "" {
+		nextDigest = nextDigestAt(cfg, time.Now())
+		log.Printf("Next digest at %s", nextDigest.Format("Mon Jan 2, 3:04 PM MST"))
+	}
+
+	if cfg.DiscordBotToken != "" {
+		app.acks = newAckTracker(app.client, cfg)
+	}
+
+	if cfg.MetricsAddr != "" {
+		if app.metrics == nil {
+			app.metrics = &metrics{}
+		}
+		go serveMetrics(cfg.MetricsAddr, cfg, app.metrics)
+	}
+
+	if cfg.PreflightCheck {
+		runPreflight(cfg)
+	}
+
+	var backoff *pollBackoff
+	if cfg.PollCron != nil {
+		log.Printf("Running in daemon mode on schedule %q (%s)", cfg.PollCron, cfg.Timezone)
+	} else if cfg.AdaptivePoll {
+		backoff = newPollBackoff(cfg.PollMinInterval, cfg.PollMaxInterval)
+		log.Printf("Running in daemon mode, polling every %s to %s", cfg.PollMinInterval, cfg.PollMaxInterval)
+	} else {
+		log.Printf("Running in daemon mode, polling every %s", cfg.PollInterval)
+	}
+	app.drainOnSignal(cfg.ShutdownTimeout)
+	var nextConfigRefresh time.Time
+	if app.configSource != "" {
+		app.reloadOnHangup()
+		if cfg.ConfigRefreshInterval > 0 {
+			nextConfigRefresh = time.Now().Add(cfg.ConfigRefreshInterval)
+		}
+	}
+	for !app.stopped() {
+		setLogTrace(newTraceID())
+		sent, err := app.runCycle()
+		if err != nil {
+			log.Printf("Error %s", err)
+		}
+		app.errs.report("cycle")
+		app.pushMetrics()
+		if app.stopped() {
+			break
+		}
+		if app.counter != nil && !time.Now().Before(nextStats) {
+			postStats(app.client, cfg, app.counter)
+			nextStats = time.Now().Add(cfg.StatsInterval)
+		}
+		if health != nil && !time.Now().Before(nextHealthcheck) {
+			health.Check()
+			nextHealthcheck = time.Now().Add(cfg.WebhookHealthInterval)
+		}
+		if !nextConfigRefresh.IsZero() && !time.Now().Before(nextConfigRefresh) {
+			app.reloadPolicy()
+			nextConfigRefresh = time.Now().Add(cfg.ConfigRefreshInterval)
+		}
+		if !nextDigest.IsZero() && !time.Now().Before(nextDigest) {
+			if err := postDigest(app.client, cfg); err != nil {
+				log.Printf("Error posting digest: %s", err)
+			}
+			nextDigest = nextDigestAt(cfg, time.Now())
+		}
+		// A rate-limited fetch is not retried within the cycle; the next
+		// one simply waits at least as long as the feed asked.
+		retryAfter := feedRetryAfter(err)
+		if retryAfter > 0 {
+			log.Printf("Feed asked us to back off for %s", retryAfter)
+		}
+		if cfg.PollCron != nil {
+			next := cfg.PollCron.Next(time.Now().Add(retryAfter))
+			if next.IsZero() {
+				log.Fatalf("Error: POLL_CRON %q has no further runs", cfg.PollCron)
+			}
+			app.sleep(time.Until(next))
+			continue
+		}
+		interval := cfg.PollInterval
+		if backoff != nil {
+			interval = backoff.Next(err == nil && sent > 0)
+		}
+		app.sleep(max(interval, retryAfter))
+	}
+	if err := store.Save(); err != nil {
+		log.Fatalf("Error saving sent incidents file: %s", err)
+	}
+	log.Println("State saved, exiting.")
+}
+
+// pendingAlert is a matched, not-yet-sent incident awaiting delivery.
+type pendingAlert struct {
+	key        string
+	incident   Incident
+	parsedTime time.Time
+	timeParsed bool
+	// extraFields are enrichment fields appended after the configured ones.
+	extraFields []EmbedField
+	// previousProblem is set when this is an update to an earlier alert, and
+	// originalURL links to that alert's message when it is known.
+	previousProblem string
+	originalURL     string
+	// firstSeen is when this run first found the incident in the feed.
+	firstSeen time.Time
+	// sequence is the alert number shown in the footer, or 0 if none.
+	sequence int64
+	// futureTime flags a timestamp ahead of the clock under FUTURE_TS_POLICY=flag.
+	futureTime bool
+	// replayOf is when a --replay-last alert was first sent; it is zero for
+	// live alerts.
+	replayOf time.Time
+	// amendedFrom is the problem of an earlier version of the call listed in
+	// the same response, when this is the amended one.
+	amendedFrom string
+}
+
+// newPendingAlert wraps an incident for delivery, parsing its timestamp into
+// the display zone and falling back to now if it does not parse. Timestamps
+// further ahead than FUTURE_TS_TOLERANCE are clamped to now or flagged per
+// FUTURE_TS_POLICY.
+func newPendingAlert(cfg *Config, key string, incident Incident) pendingAlert {
+	alert := pendingAlert{key: key, incident: incident, firstSeen: time.Now()}
+	parsedTime, err := time.Parse(incidentTimeLayout, incident.Timestamp)
 	if err != nil {
-		log.Fatalf("Error reading API response body: %s", err)
+		log.Printf("Error parsing timestamp for incident, using current time. Error: %v", err)
+		parsedTime = time.Now()
+	} else {
+		alert.timeParsed = true
+	}
+	if alert.timeParsed {
+		if ahead := time.Until(parsedTime); ahead > cfg.FutureTSTolerance && cfg.FutureTSPolicy != "pass" {
+			log.Printf("Warning: %q is timestamped %s in the future", key, ahead.Round(time.Second))
+			if cfg.FutureTSPolicy == "clamp" {
+				parsedTime = time.Now()
+			} else {
+				alert.futureTime = true
+			}
+		}
 	}
+	alert.parsedTime = parsedTime.In(locationFor(cfg, incident))
+	return alert
+}
+
+// pushMetrics sends the metrics to PUSHGATEWAY_URL, if set: the counters
+// from a daemon, the latest run's gauges from a one-shot run. A failed push
+// is logged and otherwise ignored.
+func (a *App) pushMetrics() {
+	if a.cfg.PushgatewayURL == "" {
+		return
+	}
+	if err := pushMetrics(a.client, a.cfg, a.metrics, !daemonMode(a.cfg)); err != nil {
+		log.Printf("Error pushing metrics: %s", err)
+	}
+}
+
+// runCycle fetches the feed once, alerts on any new matching incidents, and
+// returns how many alerts were sent. Its errors are counted in a.errs.
+func (a *App) runCycle() (int, error) {
+	cfg := a.cfg
+	a.errs = errorSummary{}
 
 	var incidents []Incident
-	if err := json.Unmarshal(body, &incidents); err != nil {
-		log.Fatalf("Error unmarshalling JSON: %s", err)
+	var err error
+	if daemonMode(cfg) {
+		incidents, err = fetchAllIncidents(a.apiClient, cfg)
+	} else {
+		incidents, err = fetchWithRetry(a.apiClient, cfg, a.errs)
+	}
+	if err != nil {
+		var parseErr *feedParseError
+		if errors.As(err, &parseErr) {
+			a.errs.add(errParse)
+		} else {
+			a.errs.add(errFetch)
+		}
+		if a.metrics != nil {
+			a.metrics.recordFetchError()
+		}
+		return 0, err
+	}
+	incidents, duplicates := dedupeByKey(incidents, cfg.KeyFor)
+	if duplicates > 0 {
+		log.Printf("Note: feed listed %d duplicate incidents, keeping the first of each key", duplicates)
+	}
+	incidents, amended := mergeAmendments(cfg, incidents)
+	if len(amended) > 0 {
+		log.Printf("Note: feed listed %d amended incidents alongside their earlier versions, keeping the newest of each", len(amended))
 	}
 
-	log.Println("Searching for new MVC Incidents from RWECC API...")
-	newAlertsSent := 0
+	log.Printf("Searching for new incidents matching %s from RWECC API...", cfg.IncidentFilters)
 
+	var pending, updates []pendingAlert
+	var active []Incident
+	seen := make(map[string]bool)
+	stale := 0
+	// newMatched counts matching incidents seen for the first time, before
+	// any suppression, for the anomaly rate.
+	newMatched := 0
+	dropped := make(dropSummary)
+	droppedLogged := make(map[string]bool)
 	for _, incident := range incidents {
-		incidentKey := incident.Timestamp + " " + incident.Address
+		incidentKey := cfg.KeyFor(incident)
+		seen[incidentKey] = true
+		matched, reason := runFilters(a.filters, incident)
+		if a.counter != nil {
+			a.counter.Observe(incidentKey, matched, time.Now())
+		}
+
+		if !matched {
+			dropped[reason]++
+			if !a.droppedLogged[incidentKey] {
+				log.Printf("Dropped %s at %s: %s", incident.Problem, incident.Address, reason)
+			}
+			droppedLogged[incidentKey] = true
+			continue
+		}
+		if a.tally != nil {
+			a.tally.Record(cfg, incidentKey, incident)
+		}
+		ignored, err := a.store.Has(ignoreKey(incidentKey))
+		if err != nil {
+			log.Printf("Error checking ignore set for %q, skipping: %s", incidentKey, err)
+			a.errs.add(errState)
+			continue
+		}
+		if ignored {
+			// An ignored incident still counts towards the anomaly rate once;
+			// marking it keeps later cycles from counting it again.
+			if sent, err := a.store.Has(incidentKey); err == nil && !sent {
+				newMatched++
+				if err := a.store.Mark(incidentKey); err != nil {
+					log.Printf("Error marking %q as sent: %s", incidentKey, err)
+					a.errs.add(errState)
+				}
+			}
+			continue
+		}
+		// Ignored incidents stay out of the overview, metrics and reports.
+		active = append(active, incident)
+		alreadySent, err := a.store.Has(incidentKey)
+		if err != nil {
+			log.Printf("Error checking state for %q, skipping: %s", incidentKey, err)
+			a.errs.add(errState)
+			continue
+		}
+		if alreadySent {
+			if previous := a.tracked[incidentKey]; previous != nil && problemChanged(previous.Problem, incident.Problem, cfg.UpdateThreshold) {
+				alert := newPendingAlert(cfg, incidentKey, incident)
+				alert.previousProblem = previous.Problem
+				alert.originalURL = previous.messageLink(cfg.DiscordGuildID)
+				alert.sequence = previous.Sequence
+				updates = append(updates, alert)
+			}
+			continue
+		}
+
+		if incident.Lat == 0 && incident.Long == 0 && a.geocoder != nil {
+			a.fillCoordinates(&incident)
+		}
+		alert := newPendingAlert(cfg, incidentKey, incident)
+		alert.amendedFrom = amended[incidentKey]
+		if !alert.timeParsed {
+			a.errs.add(errParse)
+		}
+		if alert.timeParsed && alert.parsedTime.Before(a.staleBefore) {
+			stale++
+			if err := a.store.Mark(incidentKey); err != nil {
+				log.Printf("Error marking %q as sent: %s", incidentKey, err)
+				a.errs.add(errState)
+			}
+			continue
+		}
+		pending = append(pending, alert)
+		if !a.anomalyCounted[incidentKey] {
+			a.anomalyCounted[incidentKey] = true
+			newMatched++
+		}
+	}
+	for key := range a.anomalyCounted {
+		if !seen[key] {
+			delete(a.anomalyCounted, key)
+		}
+	}
+	if !a.staleBefore.IsZero() {
+		log.Printf("Marked %d incidents older than %s as sent without alerting.", stale, a.staleBefore.Format(time.RFC3339))
+		a.staleBefore = time.Time{}
+	}
 
-		if strings.Contains(incident.Problem, "MVC") && !sentIncidents[incidentKey] {
-			log.Printf("Found new MVC at %s. Sending to Discord.", incident.Address)
+	a.droppedLogged = droppedLogged
+	if len(dropped) > 0 {
+		log.Printf("Filtered out %d incidents: %s", dropped.total(), dropped)
+	}
 
-			loc, _ := time.LoadLocation("America/New_York")
-			parsedTime, err := time.Parse("2006-01-02 15:04:05.000", incident.Timestamp)
+	// Send oldest first so the channel reads chronologically; incidents with
+	// unparseable timestamps go last.
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].timeParsed != pending[j].timeParsed {
+			return pending[i].timeParsed
+		}
+		return pending[i].parsedTime.Before(pending[j].parsedTime)
+	})
+
+	newAlertsSent := 0
+	alerted := make(map[string]bool)
+	for i, alert := range pending {
+		if a.stopped() {
+			log.Printf("Shutting down with %d alerts unsent; they will be sent after restart", len(pending)-i)
+			break
+		}
+		if cfg.LocationCooldown > 0 {
+			cooling, age, err := locationCoolingDown(a.store, cfg, alert.incident)
 			if err != nil {
-				log.Printf("Error parsing timestamp for incident, using current time. Error: %v", err)
-				parsedTime = time.Now()
+				log.Printf("Error checking location cooldown for %q: %s", alert.key, err)
+				a.errs.add(errState)
+			} else if cooling {
+				log.Printf("Suppressing %s at %s: alerted for this location %s ago.", alert.incident.Problem, alert.incident.Address, age.Round(time.Second))
+				if err := a.store.Mark(alert.key); err != nil {
+					log.Printf("Error marking %q as sent: %s", alert.key, err)
+					a.errs.add(errState)
+				}
+				continue
+			}
+		}
+		if cfg.FuzzyDedupWindow > 0 {
+			recent, age, err := recentlyAlerted(a.store, cfg, alert.incident)
+			if err != nil {
+				log.Printf("Error checking fuzzy state for %q: %s", alert.key, err)
+				a.errs.add(errState)
+			} else if recent {
+				var changed []string
+				if len(cfg.SignificantFields) > 0 {
+					if changed, err = changedFields(a.store, cfg, alert.incident); err != nil {
+						log.Printf("Error comparing fields for %q: %s", alert.key, err)
+						a.errs.add(errState)
+					}
+				}
+				if len(changed) == 0 {
+					log.Printf("Suppressing %s at %s: already alerted %s ago.", alert.incident.Problem, alert.incident.Address, age.Round(time.Second))
+					if err := a.store.Mark(alert.key); err != nil {
+						log.Printf("Error marking %q as sent: %s", alert.key, err)
+						a.errs.add(errState)
+					}
+					continue
+				}
+				log.Printf("Re-alerting %s at %s: %s changed since the alert %s ago.", alert.incident.Problem, alert.incident.Address, strings.Join(changed, ", "), age.Round(time.Second))
+			}
+		}
+
+		// Fail open: if the endpoint can't answer, alerting twice beats
+		// not alerting at all.
+		if a.external != nil {
+			reported, err := a.external.Reported(alert.key)
+			if err != nil {
+				log.Printf("Error checking EXTERNAL_DEDUP_URL for %q, alerting anyway: %s", alert.key, err)
+				a.errs.add(errDedupAPI)
+			} else if reported {
+				log.Printf("Suppressing %s at %s: already reported by another tool.", alert.incident.Problem, alert.incident.Address)
+				if err := a.store.Mark(alert.key); err != nil {
+					log.Printf("Error marking %q as sent: %s", alert.key, err)
+					a.errs.add(errState)
+				}
+				continue
+			}
+		}
+
+		log.Printf("Found new %s at %s. Sending to Discord.", alert.incident.Problem, alert.incident.Address)
+
+		if a.tts != nil {
+			audioURL, err := a.tts.AudioURL(alert.key, ttsText(cfg, alert.incident))
+			if err != nil {
+				log.Printf("Error generating TTS audio for %q: %s", alert.key, err)
+				a.errs.add(errEnrich)
+			} else {
+				alert.extraFields = append(alert.extraFields, EmbedField{Name: "Audio", Value: fmt.Sprintf("[▶️ Listen](%s)", audioURL)})
+			}
+		}
+
+		if len(cfg.Facilities) > 0 {
+			if field, ok := facilityField(cfg, alert.incident); ok {
+				alert.extraFields = append(alert.extraFields, field)
+			}
+		}
+
+		// Like the facility field, a redacted incident gets no drive time,
+		// since it would hint at the location.
+		if a.routing != nil && (alert.incident.Lat != 0 || alert.incident.Long != 0) && !addressRedacted(cfg, alert.incident) {
+			estimate, err := a.routing.DriveTime(LatLng{Lat: alert.incident.Lat, Long: alert.incident.Long})
+			if err != nil {
+				log.Printf("Error estimating drive time for %q, omitting: %s", alert.key, err)
+				a.errs.add(errEnrich)
+			} else {
+				alert.extraFields = append(alert.extraFields, EmbedField{Name: "Drive time", Value: estimate.String()})
+			}
+		}
+
+		if a.weather != nil && (alert.incident.Lat != 0 || alert.incident.Long != 0) {
+			conditions, err := a.weather.Conditions(alert.incident.Lat, alert.incident.Long, time.Now())
+			if err != nil {
+				log.Printf("Error looking up weather for %q, omitting: %s", alert.key, err)
+				a.errs.add(errEnrich)
+			} else {
+				alert.extraFields = append(alert.extraFields, EmbedField{Name: "Weather", Value: conditions})
 			}
-			easternTime := parsedTime.In(loc)
+		}
 
-			sendToDiscord(webhookURL, incident, easternTime, mapsAPIKey)
+		var original DiscordMessage
+		if !a.ndjsonOnly {
+			// A number is taken once per incident and kept across failed
+			// cycles, so only delivered alerts use one up.
+			if seq, ok := a.sequences[alert.key]; ok {
+				alert.sequence = seq
+			} else if seq, err := a.store.NextSequence(); err != nil {
+				log.Printf("Error allocating alert number for %q: %s", alert.key, err)
+				a.errs.add(errState)
+			} else {
+				a.sequences[alert.key] = seq
+				alert.sequence = seq
+			}
+			destinations := destinationsFor(cfg, alert.incident)
+			delivered := len(destinations) == 0
+			for _, webhookURL := range destinations {
+				msg, err := a.deliver(webhookURL, alert)
+				if err != nil {
+					continue
+				}
+				delivered = true
+				if original.ID == "" {
+					original = msg
+				}
+			}
+			// Leaving it unmarked retries the whole alert next cycle.
+			if !delivered {
+				log.Printf("Error: %q was not delivered anywhere, leaving it unsent", alert.key)
+				continue
+			}
+		}
+		delete(a.sequences, alert.key)
+		a.notifyAll(alert)
+		if a.acks != nil {
+			a.acks.Watch(alert.key, alert.incident, original)
+		}
+
+		if err := a.store.Mark(alert.key); err != nil {
+			log.Printf("Error marking %q as sent: %s", alert.key, err)
+			a.errs.add(errState)
+		}
+		if a.tracked != nil {
+			a.tracked[alert.key] = &trackedIncident{
+				Problem:   alert.incident.Problem,
+				SentAt:    time.Now(),
+				MessageID: original.ID,
+				ChannelID: original.ChannelID,
+				GuildID:   original.GuildID,
+				Sequence:  alert.sequence,
+			}
+		}
+		if cfg.FuzzyDedupWindow > 0 {
+			if err := a.store.Touch(fuzzyKey(cfg, alert.incident)); err != nil {
+				log.Printf("Error recording fuzzy key for %q: %s", alert.key, err)
+				a.errs.add(errState)
+			}
+			for _, field := range cfg.SignificantFields {
+				if err := a.store.Touch(fieldKey(cfg, alert.incident, field)); err != nil {
+					log.Printf("Error recording %s for %q: %s", field, alert.key, err)
+					a.errs.add(errState)
+				}
+			}
+		}
+		if key, ok := locationKey(cfg, alert.incident); ok && cfg.LocationCooldown > 0 {
+			if err := a.store.Touch(key); err != nil {
+				log.Printf("Error recording location for %q: %s", alert.key, err)
+				a.errs.add(errState)
+			}
+		}
+		alerted[alert.key] = true
+		newAlertsSent++
+	}
 
-			sentIncidents[incidentKey] = true
-			newAlertsSent++
+	for _, alert := range updates {
+		if a.stopped() {
+			break
 		}
+		if err := a.sendUpdate(alert); err != nil {
+			log.Printf("Error: %s, retrying next cycle", err)
+		}
+	}
+	if a.tracked != nil {
+		a.saveTracked(seen)
+	}
+	if a.acks != nil {
+		a.acks.Poll(seen)
 	}
 
-	if newAlertsSent > 0 {
-		if err := saveSentIncidents(stateFilename, sentIncidents); err != nil {
-			log.Printf("Error saving sent incidents file: %s", err)
+	if err := a.store.Save(); err != nil {
+		log.Printf("Error saving sent incidents file: %s", err)
+		a.errs.add(errState)
+	}
+	if a.tally != nil {
+		if err := saveTally(cfg.StatsFilename, a.tally); err != nil {
+			log.Printf("Error saving stats: %s", err)
+			a.errs.add(errState)
+		}
+	}
+	if a.anomaly != nil {
+		a.checkAnomaly(newMatched)
+	}
+	if cfg.OverviewWebhookURL != "" {
+		a.updateOverview(active)
+	}
+	if a.metrics != nil {
+		a.metrics.recordCycle(active, newAlertsSent)
+	}
+	if cfg.SnapshotFilename != "" {
+		if err := saveSnapshot(cfg.SnapshotFilename, active); err != nil {
+			log.Printf("Error saving snapshot: %s", err)
+		}
+	}
+	if a.htmlReport != "" {
+		if err := writeHTMLReport(a.htmlReport, cfg, active, alerted, newAlertsSent); err != nil {
+			log.Printf("Error writing HTML report: %s", err)
 		}
 	}
 	log.Printf("Search complete. Sent %d new alerts.", newAlertsSent)
+	return newAlertsSent, nil
+}
+
+// fillCoordinates geocodes an address-only incident so it still gets a map.
+func (a *App) fillCoordinates(incident *Incident) {
+	point, err := a.geocoder.Lookup(incident.Address)
+	if err != nil {
+		log.Printf("Error geocoding %q: %s", incident.Address, err)
+		a.errs.add(errEnrich)
+		return
+	}
+	if point == nil {
+		log.Printf("No geocoding match for %q", incident.Address)
+		return
+	}
+	incident.Lat, incident.Long = point.Lat, point.Long
+}
+
+// checkAnomaly folds this cycle's new matched incidents, counted before
+// dedup, cooldown and ignore suppression, into the hourly history and posts
+// a notice when the current hour is well above the baseline.
+func (a *App) checkAnomaly(matched int) {
+	a.anomaly.Record(matched, time.Now(), a.cfg.AnomalyBaselineHours)
+	if notice := checkAnomaly(a.cfg, a.anomaly); notice != "" {
+		log.Println(notice)
+		if err := postContent(a.client, a.cfg.AnomalyWebhookURL, notice); err != nil {
+			log.Printf("Error sending anomaly notice: %s", err)
+		}
+	}
+	if err := saveAnomalyState(a.cfg.AnomalyStateFilename, a.anomaly); err != nil {
+		log.Printf("Error saving anomaly state: %s", err)
+	}
+}
+
+// deliver sends one incident to one webhook and archives the result. It
+// returns the posted message, which is empty if the send failed.
+func (a *App) deliver(webhookURL string, alert pendingAlert) (DiscordMessage, error) {
+	msg, err := sendToDiscord(a.client, a.cfg, webhookURL, alert)
+	if err != nil {
+		log.Printf("Error sending to Discord: %s", err)
+		if a.cfg.FallbackWebhookURL == "" || webhookURL == a.cfg.FallbackWebhookURL {
+			a.errs.add(errSend)
+			return msg, err
+		}
+		if msg, err = sendToDiscord(a.client, a.cfg, a.cfg.FallbackWebhookURL, alert); err != nil {
+			log.Printf("Error sending to FALLBACK_WEBHOOK_URL: %s", err)
+			a.errs.add(errSend)
+			return msg, err
+		}
+		log.Printf("Delivered %q via FALLBACK_WEBHOOK_URL as message %s", alert.key, msg.ID)
+	} else {
+		log.Printf("Delivered %q as Discord message %s", alert.key, msg.ID)
+	}
+	if a.cfg.ThreadNameTemplate != nil && a.cfg.DiscordBotToken != "" && alert.previousProblem == "" {
+		if err := startThread(a.client, a.cfg, msg, threadName(a.cfg, alert)); err != nil {
+			log.Printf("Error starting thread for %q: %s", alert.key, err)
+			a.errs.add(errSend)
+		}
+	}
+
+	if a.cfg.ArchiveFilename != "" {
+		record := ArchiveRecord{
+			Key:       alert.key,
+			Incident:  alert.incident,
+			MessageID: msg.ID,
+			ChannelID: msg.ChannelID,
+			FirstSeen: alert.firstSeen,
+			SentAt:    time.Now(),
+		}
+		filename := expandDateTemplate(a.cfg.ArchiveFilename, record.SentAt.In(a.cfg.Timezone))
+		if err := rotateArchiveIfNeeded(a.cfg, filename); err != nil {
+			log.Printf("Error rotating archive: %s", err)
+			a.errs.add(errArchive)
+		}
+		if err := appendArchive(filename, record); err != nil {
+			log.Printf("Error writing to archive: %s", err)
+			a.errs.add(errArchive)
+		}
+	}
+	return msg, nil
 }
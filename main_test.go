@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testFeed is a saved RWECC response with two MVCs and one incident the
+// default INCIDENT_FILTERS drop.
+const testFeed = `[
+ {"jurisdiction":"Raleigh","problem":"MVC PI","address":"100 Main St","lat":35.78,"long":-78.64,"timestamp":"2025-09-26 08:01:02.000"},
+ {"jurisdiction":"Cary","problem":"MVC DAMAGE","address":"5 Oak Ave","lat":35.7912,"long":-78.7811,"timestamp":"2025-09-26 07:01:02.000"},
+ {"jurisdiction":"Cary","problem":"FIRE ALARM","address":"9 Elm","lat":35.7,"long":-78.7,"timestamp":"2025-09-26 07:30:02.000"}
+]`
+
+// testHook is a Discord webhook stand-in that answers with status and
+// counts the posts it receives.
+type testHook struct {
+	*httptest.Server
+	status int
+	posts  atomic.Int32
+}
+
+func newTestHook(t *testing.T, status int) *testHook {
+	t.Helper()
+	h := &testHook{status: status}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.posts.Add(1)
+		w.WriteHeader(h.status)
+		w.Write([]byte(`{"id": "1", "channel_id": "9"}`))
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+// newTestApp builds an App the way main does, in a scratch directory with
+// feed as a local RWECC_URL file. env holds extra settings.
+func newTestApp(t *testing.T, feed string, hook *testHook, env map[string]string) *App {
+	t.Helper()
+	dir := t.TempDir()
+	for name, file := range map[string]string{
+		"STATE_FILE":          "state.json",
+		"UPDATE_STATE_FILE":   "update_state.json",
+		"ANOMALY_STATE_FILE":  "anomaly_state.json",
+		"OVERVIEW_STATE_FILE": "overview_state.json",
+		"CONFIG_CACHE_FILE":   "config_cache.yaml",
+	} {
+		t.Setenv(name, filepath.Join(dir, file))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "feed.json"), []byte(feed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RWECC_URL", filepath.Join(dir, "feed.json"))
+	t.Setenv("RWECC_DISCORD_HOOK", hook.URL)
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := newStateStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{
+		cfg:            cfg,
+		client:         hook.Client(),
+		apiClient:      newAPIClient(cfg),
+		store:          store,
+		filters:        buildFilters(cfg),
+		sequences:      make(map[string]int64),
+		anomalyCounted: make(map[string]bool),
+		anomaly:        &anomalyState{},
+		errs:           make(errorSummary),
+	}
+	return a
+}
+
+func TestAnomalyCountsMatchesBeforeSuppression(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		env    map[string]string
+		ignore string
+		sent   int
+	}{
+		{"all delivered", http.StatusOK, nil, "", 2},
+		{"one ignored", http.StatusOK, nil, "2025-09-26 07:01:02.000 5 Oak Ave", 1},
+		{"location cooldown", http.StatusOK, map[string]string{"LOCATION_COOLDOWN_MINUTES": "60", "LOCATION_COOLDOWN_PRECISION": "0"}, "", 1},
+		{"delivery failing", http.StatusBadRequest, nil, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"ANOMALY_FACTOR": "3"}
+			for name, value := range tt.env {
+				env[name] = value
+			}
+			a := newTestApp(t, testFeed, newTestHook(t, tt.status), env)
+			if tt.ignore != "" {
+				a.store.Mark(ignoreKey(tt.ignore))
+			}
+			// The second cycle sees the same feed and must not count again.
+			for cycle := 1; cycle <= 2; cycle++ {
+				sent, err := a.runCycle()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cycle == 1 && sent != tt.sent {
+					t.Errorf("sent %d alerts, want %d", sent, tt.sent)
+				}
+				if a.anomaly.Count != 2 {
+					t.Errorf("cycle %d: anomaly count = %d, want the 2 matched incidents", cycle, a.anomaly.Count)
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoredIncidentsLeaveActive(t *testing.T) {
+	tests := []struct {
+		name   string
+		ignore []string
+		want   []string
+	}{
+		{"none ignored", nil, []string{"100 Main St", "5 Oak Ave"}},
+		{"one ignored", []string{"2025-09-26 07:01:02.000 5 Oak Ave"}, []string{"100 Main St"}},
+		{"all ignored", []string{"2025-09-26 07:01:02.000 5 Oak Ave", "2025-09-26 08:01:02.000 100 Main St"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+			a := newTestApp(t, testFeed, newTestHook(t, http.StatusOK), map[string]string{"SNAPSHOT_FILE": snapshot})
+			for _, key := range tt.ignore {
+				a.store.Mark(ignoreKey(key))
+			}
+			if _, err := a.runCycle(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got fetchSnapshot
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			var addresses []string
+			for _, incident := range got.Active {
+				addresses = append(addresses, incident.Address)
+			}
+			slices.Sort(addresses)
+			if !slices.Equal(addresses, tt.want) {
+				t.Errorf("active = %v, want %v", addresses, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchFeedBodyMethod(t *testing.T) {
+	tests := []struct {
+		name            string
+		method, body    string
+		wantContentType string
+	}{
+		{"plain GET", http.MethodGet, "", ""},
+		{"POSTed query", http.MethodPost, `{"where":"1=1"}`, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotBody, gotType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				gotMethod, gotBody, gotType = r.Method, string(data), r.Header.Get("Content-Type")
+				w.Write([]byte(`[]`))
+			}))
+			defer srv.Close()
+
+			cfg := &Config{APIURL: srv.URL, APIMethod: tt.method, APIBody: tt.body, APIContentType: "application/json"}
+			if _, err := fetchFeedBody(srv.Client(), cfg); err != nil {
+				t.Fatal(err)
+			}
+			if gotMethod != tt.method || gotBody != tt.body || gotType != tt.wantContentType {
+				t.Errorf("got %s %q (Content-Type %q), want %s %q (%q)", gotMethod, gotBody, gotType, tt.method, tt.body, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func TestNewPendingAlertFutureTimestamps(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		ahead      time.Duration
+		clamped    bool
+		futureTime bool
+	}{
+		{"pass", "pass", time.Hour, false, false},
+		{"clamp", "clamp", time.Hour, true, false},
+		{"flag", "flag", time.Hour, false, true},
+		{"clamp within tolerance", "clamp", time.Minute, false, false},
+		{"flag within tolerance", "flag", time.Minute, false, false},
+		{"past", "flag", -time.Hour, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Timezone: time.UTC, FutureTSPolicy: tt.policy, FutureTSTolerance: 5 * time.Minute}
+			at := time.Now().UTC().Add(tt.ahead).Truncate(time.Millisecond)
+			alert := newPendingAlert(cfg, "k", Incident{Problem: "MVC PI", Timestamp: at.Format(incidentTimeLayout)})
+			if alert.futureTime != tt.futureTime {
+				t.Errorf("futureTime = %v, want %v", alert.futureTime, tt.futureTime)
+			}
+			clamped := !alert.parsedTime.Equal(at)
+			if clamped != tt.clamped {
+				t.Errorf("parsedTime = %s for a %s timestamp, clamped = %v, want %v", alert.parsedTime, at, clamped, tt.clamped)
+			}
+			if clamped && time.Since(alert.parsedTime) > time.Minute {
+				t.Errorf("clamped to %s, want now", alert.parsedTime)
+			}
+		})
+	}
+}
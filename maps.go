@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxMapMarkers caps the markers in one static map so the URL stays well
+// under Google's 8192-character limit.
+const maxMapMarkers = 50
+
+// mapTypes are the MAP_TYPE values Google Static Maps accepts.
+var mapTypes = []string{"roadmap", "satellite", "hybrid", "terrain"}
+
+// LatLng is a single coordinate pair plotted on a map.
+type LatLng struct {
+	Lat  float64
+	Long float64
+}
+
+// buildMapURL returns a Google Static Maps URL marking each point. A single
+// point is centred at a fixed zoom; several points are left for Google to
+// centre and zoom so they all fit.
+func buildMapURL(points []LatLng, apiKey, mapType string) string {
+	if len(points) > maxMapMarkers {
+		points = points[:maxMapMarkers]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "https://maps.googleapis.com/maps/api/staticmap?size=300x300&maptype=%s", mapType)
+	if len(points) == 1 {
+		fmt.Fprintf(&b, "&center=%.6f,%.6f&zoom=14", points[0].Lat, points[0].Long)
+	}
+	for _, p := range points {
+		fmt.Fprintf(&b, "&markers=color:red%%7C%.6f,%.6f", p.Lat, p.Long)
+	}
+	fmt.Fprintf(&b, "&key=%s", apiKey)
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBuildMapURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		points  []LatLng
+		mapType string
+		center  string
+		markers int
+	}{
+		{"single point", []LatLng{{35.78, -78.64}}, "roadmap", "35.780000,-78.640000", 1},
+		{"satellite", []LatLng{{35.78, -78.64}}, "satellite", "35.780000,-78.640000", 1},
+		{"several points", []LatLng{{35.78, -78.64}, {35.79, -78.78}}, "hybrid", "", 2},
+		{"capped markers", make([]LatLng, maxMapMarkers+10), "terrain", "", maxMapMarkers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(buildMapURL(tt.points, "KEY", tt.mapType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := u.Query()
+			if got := q.Get("maptype"); got != tt.mapType {
+				t.Errorf("maptype = %q, want %q", got, tt.mapType)
+			}
+			if got := q.Get("center"); got != tt.center {
+				t.Errorf("center = %q, want %q", got, tt.center)
+			}
+			if got := len(q["markers"]); got != tt.markers {
+				t.Errorf("%d markers, want %d", got, tt.markers)
+			}
+			if q.Get("key") != "KEY" || !strings.HasPrefix(u.String(), "https://maps.googleapis.com/maps/api/staticmap?") {
+				t.Errorf("unexpected URL %s", u)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// matrixNotifier posts alerts to a Matrix room through the client-server API.
+type matrixNotifier struct {
+	client     *http.Client
+	cfg        *Config
+	homeserver string
+	token      string
+	roomID     string
+}
+
+// newMatrixNotifier returns a notifier for MATRIX_ROOM_ID on MATRIX_HOMESERVER.
+func newMatrixNotifier(client *http.Client, cfg *Config) *matrixNotifier {
+	return &matrixNotifier{
+		client:     client,
+		cfg:        cfg,
+		homeserver: strings.TrimRight(cfg.MatrixHomeserver, "/"),
+		token:      cfg.MatrixToken,
+		roomID:     cfg.MatrixRoomID,
+	}
+}
+
+func (n *matrixNotifier) Name() string {
+	return "Matrix"
+}
+
+// Notify sends the alert as an m.room.message. The transaction ID is fixed
+// per alert, so a retried PUT is deduplicated by the homeserver.
+func (n *matrixNotifier) Notify(alert pendingAlert) error {
+	body, formatted := formatMatrixMessage(n.cfg, alert)
+	payload, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           body,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	})
+	if err != nil {
+		return err
+	}
+
+	txnID := fmt.Sprintf("911-reporting-%d", time.Now().UnixNano())
+	sendURL := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		n.homeserver, url.PathEscape(n.roomID), url.PathEscape(txnID))
+	headers := http.Header{"Authorization": {"Bearer " + n.token}}
+	_, err = sendWithRetry(n.client, n.cfg, http.MethodPut, sendURL, payload, headers)
+	return err
+}
+
+// formatMatrixMessage renders the plain and HTML bodies at MATRIX_VERBOSITY,
+// with the problem in the severity color and a map link when the location
+// may be shown.
+func formatMatrixMessage(cfg *Config, alert pendingAlert) (string, string) {
+	incident := alert.incident
+	problem := displayProblem(cfg, incident.Problem)
+	address := displayAddress(cfg, incident)
+	when := alert.parsedTime.Format("Mon Jan 2, 3:04 PM MST")
+
+	body := alertText(cfg, alert, cfg.MatrixVerbosity)
+	formatted := fmt.Sprintf(`<font color="#%06x"><b>%s</b></font><br>`,
+		severityColor(severityOf(cfg, incident)), html.EscapeString(problem))
+	if cfg.MatrixVerbosity == VerbosityTerse {
+		formatted += html.EscapeString(address)
+	} else {
+		formatted += fmt.Sprintf("%s, %s<br>%s",
+			html.EscapeString(address), html.EscapeString(incident.Jurisdiction), html.EscapeString(when))
+	}
+	if cfg.MatrixVerbosity == VerbosityVerbose {
+		for _, field := range detailFields(cfg, alert) {
+			formatted += fmt.Sprintf("<br><b>%s:</b> %s", html.EscapeString(field.Name), html.EscapeString(field.Value))
+		}
+	}
+
+	if (incident.Lat != 0 || incident.Long != 0) && !mapHidden(cfg, incident) {
+		mapURL := fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", incident.Lat, incident.Long)
+		body += "\n" + mapURL
+		formatted += fmt.Sprintf(`<br><a href="%s">Map</a>`, html.EscapeString(mapURL))
+	}
+	return body, formatted
+}
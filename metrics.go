@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// metrics holds the counters and latest feed snapshot for the METRICS_ADDR
+// server and PUSHGATEWAY_URL. A --dashboard process has no counters of its own and
+// instead re-reads snapshotFile on each request.
+type metrics struct {
+	mu           sync.Mutex
+	active       []Incident
+	alertsSent   int
+	cycles       int
+	fetchErrors  int
+	lastSuccess  time.Time
+	snapshotFile string
+	// lastRunSent, lastRunAt and lastRunFailed describe the latest cycle,
+	// for the gauges a one-shot run pushes instead of the counters.
+	lastRunSent   int
+	lastRunAt     time.Time
+	lastRunFailed bool
+}
+
+// fetchSnapshot is the last successful fetch as persisted to SNAPSHOT_FILE.
+type fetchSnapshot struct {
+	FetchedAt time.Time  `json:"fetched_at"`
+	Active    []Incident `json:"active"`
+}
+
+// saveSnapshot writes the matching incidents from the latest fetch.
+func saveSnapshot(filename string, active []Incident) error {
+	data, err := json.MarshalIndent(fetchSnapshot{FetchedAt: time.Now(), Active: active}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// refresh reloads the snapshot in --dashboard mode. The caller holds m.mu.
+func (m *metrics) refresh() {
+	if m.snapshotFile == "" {
+		return
+	}
+	data, err := os.ReadFile(m.snapshotFile)
+	if err != nil {
+		log.Printf("Error reading snapshot: %s", err)
+		return
+	}
+	var snapshot fetchSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		log.Printf("Error decoding snapshot: %s", err)
+		return
+	}
+	m.active, m.lastSuccess = snapshot.Active, snapshot.FetchedAt
+}
+
+// recordCycle stores the result of a successful cycle.
+func (m *metrics) recordCycle(active []Incident, sent int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = active
+	m.alertsSent += sent
+	m.cycles++
+	m.lastSuccess = time.Now()
+	m.lastRunSent, m.lastRunAt, m.lastRunFailed = sent, m.lastSuccess, false
+}
+
+// recordFetchError counts a cycle whose feed fetch failed.
+func (m *metrics) recordFetchError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycles++
+	m.fetchErrors++
+	m.lastRunSent, m.lastRunAt, m.lastRunFailed = 0, time.Now(), true
+}
+
+// serveMetrics exposes /metrics in the Prometheus text format and a small
+// HTML dashboard of the active incidents at /. With STATE_ENDPOINT it also
+// serves the incidents and recent alerts as JSON at /state.
+func serveMetrics(addr string, cfg *Config, m *metrics) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	if cfg.StateEndpoint {
+		mux.HandleFunc("/state", requireBasicAuth(cfg, func(w http.ResponseWriter, r *http.Request) {
+			m.handleState(w, r, cfg)
+		}))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		m.handleDashboard(w, cfg)
+	})
+	log.Printf("Serving metrics and dashboard on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Error serving metrics: %s", err)
+	}
+}
+
+func (m *metrics) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refresh()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeMetrics(w)
+}
+
+// writeMetrics writes every metric in the Prometheus text format. The
+// caller holds m.mu.
+func (m *metrics) writeMetrics(w io.Writer) {
+	m.writeActive(w)
+	if m.snapshotFile == "" {
+		m.writeCounters(w)
+	}
+	m.writeLastSuccess(w)
+}
+
+// writeOneShotMetrics is writeMetrics for a one-shot run's push, with the
+// latest run's gauges in place of the counters.
+func (m *metrics) writeOneShotMetrics(w io.Writer) {
+	m.writeActive(w)
+	m.writeRunGauges(w)
+	m.writeLastSuccess(w)
+}
+
+func (m *metrics) writeActive(w io.Writer) {
+	fmt.Fprintf(w, "# HELP rwecc_active_incidents Matching incidents in the latest feed.\n# TYPE rwecc_active_incidents gauge\nrwecc_active_incidents %d\n", len(m.active))
+}
+
+func (m *metrics) writeLastSuccess(w io.Writer) {
+	if !m.lastSuccess.IsZero() {
+		fmt.Fprintf(w, "# HELP rwecc_last_success_timestamp_seconds When the feed was last fetched successfully.\n# TYPE rwecc_last_success_timestamp_seconds gauge\nrwecc_last_success_timestamp_seconds %d\n", m.lastSuccess.Unix())
+	}
+}
+
+// writeRunGauges writes the latest cycle as gauges. A one-shot run pushes
+// these instead of the counters, which would restart from zero every run
+// and break rate() and increase().
+func (m *metrics) writeRunGauges(w io.Writer) {
+	success := 1
+	if m.lastRunFailed {
+		success = 0
+	}
+	fmt.Fprintf(w, "# HELP rwecc_last_run_alerts_sent Alerts sent by the latest run.\n# TYPE rwecc_last_run_alerts_sent gauge\nrwecc_last_run_alerts_sent %d\n", m.lastRunSent)
+	fmt.Fprintf(w, "# HELP rwecc_last_run_success Whether the latest run fetched the feed (1) or failed (0).\n# TYPE rwecc_last_run_success gauge\nrwecc_last_run_success %d\n", success)
+	fmt.Fprintf(w, "# HELP rwecc_last_run_timestamp_seconds When the latest run finished.\n# TYPE rwecc_last_run_timestamp_seconds gauge\nrwecc_last_run_timestamp_seconds %d\n", m.lastRunAt.Unix())
+}
+
+// writeCounters writes the counters only a fetching process has.
+func (m *metrics) writeCounters(w io.Writer) {
+	fmt.Fprintf(w, "# HELP rwecc_alerts_sent_total Alerts sent since start.\n# TYPE rwecc_alerts_sent_total counter\nrwecc_alerts_sent_total %d\n", m.alertsSent)
+	fmt.Fprintf(w, "# HELP rwecc_cycles_total Poll cycles run since start.\n# TYPE rwecc_cycles_total counter\nrwecc_cycles_total %d\n", m.cycles)
+	fmt.Fprintf(w, "# HELP rwecc_fetch_errors_total Poll cycles whose feed fetch failed since start.\n# TYPE rwecc_fetch_errors_total counter\nrwecc_fetch_errors_total %d\n", m.fetchErrors)
+}
+
+// dashboardTemplate renders the active incidents as a plain table.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="30"><title>{{len .Active}} active incidents</title></head>
+<body>
+<h1>{{len .Active}} active incidents</h1>
+<p>Last fetched: {{if .LastSuccess.IsZero}}never{{else}}{{.LastSuccess.Format "Mon Jan 2, 3:04:05 PM MST"}}{{end}}</p>
+<table border="1" cellpadding="4">
+<tr><th>Problem</th><th>Address</th><th>Jurisdiction</th><th>Time</th></tr>
+{{range .Active}}<tr><td>{{.Problem}}</td><td>{{.Address}}</td><td>{{.Jurisdiction}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func (m *metrics) handleDashboard(w http.ResponseWriter, cfg *Config) {
+	type row struct{ Problem, Address, Jurisdiction, Time string }
+	m.mu.Lock()
+	m.refresh()
+	data := struct {
+		Active      []row
+		LastSuccess time.Time
+	}{LastSuccess: m.lastSuccess.In(cfg.Timezone)}
+	for _, incident := range m.active {
+		shown := incident.Timestamp
+		if at, err := time.Parse(incidentTimeLayout, incident.Timestamp); err == nil {
+			shown = at.In(locationFor(cfg, incident)).Format("Mon Jan 2, 3:04 PM MST")
+		}
+		data.Active = append(data.Active, row{
+			Problem:      displayProblem(cfg, incident.Problem),
+			Address:      displayAddress(cfg, incident),
+			Jurisdiction: incident.Jurisdiction,
+			Time:         shown,
+		})
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering dashboard: %s", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// mockWindow is how many incidents the mock feed lists at once.
+const mockWindow = 3
+
+// mockFeed serves fabricated incidents in the API's format. Each request
+// introduces the next incident from the template file, stamped with the
+// current time, and drops the oldest once mockWindow are listed, so a
+// polling client sees one new incident per fetch.
+type mockFeed struct {
+	mu        sync.Mutex
+	templates []Incident
+	listed    []Incident
+	next      int
+}
+
+// serveMockFeed runs the mock feed on addr until the process is stopped.
+func serveMockFeed(addr, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var templates []Incident
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if len(templates) == 0 {
+		return fmt.Errorf("%s contains no incidents", filename)
+	}
+
+	log.Printf("Serving %d mock incidents from %s on http://%s/", len(templates), filename, addr)
+	return http.ListenAndServe(addr, &mockFeed{templates: templates})
+}
+
+func (m *mockFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	incident := m.templates[m.next%len(m.templates)]
+	m.next++
+	incident.Timestamp = time.Now().UTC().Format(incidentTimeLayout)
+	m.listed = append(m.listed, incident)
+	if len(m.listed) > mockWindow {
+		m.listed = m.listed[1:]
+	}
+	body, err := json.Marshal(m.listed)
+	m.mu.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
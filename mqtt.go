@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttPublishTimeout bounds how long a publish waits for the broker,
+// including while the client is reconnecting.
+const mqttPublishTimeout = 15 * time.Second
+
+// mqttNotifier publishes each alert as JSON to MQTT_TOPIC, for LED signs,
+// home automation and local dashboards.
+type mqttNotifier struct {
+	client mqtt.Client
+	cfg    *Config
+}
+
+// newMQTTNotifier starts connecting to MQTT_BROKER without waiting for it.
+// The client retries the first connection and reconnects after drops, and
+// publishes made meanwhile are queued until the broker is back.
+func newMQTTNotifier(cfg *Config) *mqttNotifier {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.MQTTBroker).
+		SetClientID(cfg.MQTTClientID).
+		SetUsername(cfg.MQTTUsername).
+		SetPassword(cfg.MQTTPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(2 * time.Minute).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Printf("Connected to MQTT broker %s", cfg.MQTTBroker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("Lost connection to MQTT broker, reconnecting: %s", err)
+		})
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	return &mqttNotifier{client: client, cfg: cfg}
+}
+
+func (n *mqttNotifier) Name() string {
+	return "MQTT"
+}
+
+// Notify publishes the --emit-ndjson record for the alert.
+func (n *mqttNotifier) Notify(alert pendingAlert) error {
+	data, err := json.Marshal(newNDJSONRecord(n.cfg, alert))
+	if err != nil {
+		return err
+	}
+	token := n.client.Publish(n.cfg.MQTTTopic, n.cfg.MQTTQoS, n.cfg.MQTTRetain, data)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return errors.New("timed out waiting for the MQTT broker")
+	}
+	return token.Error()
+}
+
+// validMQTTBroker checks MQTT_BROKER is a URL paho can dial.
+func validMQTTBroker(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("unsupported scheme %q, want tcp, ssl, ws or wss", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
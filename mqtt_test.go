@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeMQTTClient records publishes; the rest of mqtt.Client is left unset.
+type fakeMQTTClient struct {
+	mqtt.Client
+	topic    string
+	payloads []string
+}
+
+func (c *fakeMQTTClient) Publish(topic string, _ byte, _ bool, payload interface{}) mqtt.Token {
+	c.topic = topic
+	c.payloads = append(c.payloads, string(payload.([]byte)))
+	return doneToken{}
+}
+
+// doneToken is an mqtt.Token that has already succeeded.
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{}          { return nil }
+func (doneToken) Error() error                   { return nil }
+
+func TestMQTTNotifierRedacts(t *testing.T) {
+	cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}, MQTTTopic: "rwecc/alerts"})
+	tests := []struct {
+		incident Incident
+		want     string
+		leak     string
+	}{
+		{Incident{Problem: "ASSAULT", Address: "12 Elm St", Timestamp: "2025-09-26 07:01:02.000"}, `"key":"sha256:`, "12 Elm St"},
+		{Incident{Problem: "MVC PI", Address: "100 Main St", Timestamp: "2025-09-26 08:01:02.000"}, `"key":"2025-09-26 08:01:02.000 100 Main St"`, ""},
+	}
+	for _, tt := range tests {
+		client := &fakeMQTTClient{}
+		n := &mqttNotifier{client: client, cfg: cfg}
+		if err := n.Notify(pendingAlert{key: cfg.KeyFor(tt.incident), incident: tt.incident}); err != nil {
+			t.Fatal(err)
+		}
+		if client.topic != "rwecc/alerts" || len(client.payloads) != 1 {
+			t.Fatalf("%s: published %d messages to %q", tt.incident.Problem, len(client.payloads), client.topic)
+		}
+		payload := client.payloads[0]
+		if !strings.Contains(payload, tt.want) {
+			t.Errorf("%s: payload %s, want %s", tt.incident.Problem, payload, tt.want)
+		}
+		if tt.leak != "" && strings.Contains(payload, tt.leak) {
+			t.Errorf("%s: payload leaks %q: %s", tt.incident.Problem, tt.leak, payload)
+		}
+	}
+}
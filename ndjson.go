@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// NDJSONRecord is one line written by --emit-ndjson.
+type NDJSONRecord struct {
+	Key      string   `json:"key"`
+	Severity Severity `json:"severity"`
+	// Time is the incident timestamp in RFC 3339, or the detection time if it did not parse.
+	Time     string   `json:"time"`
+	Incident Incident `json:"incident"`
+}
+
+// ndjsonNotifier writes each alert as a JSON line, for piping into other tools.
+type ndjsonNotifier struct {
+	enc *json.Encoder
+	cfg *Config
+}
+
+// newNDJSONNotifier writes records to w, normally stdout.
+func newNDJSONNotifier(w io.Writer, cfg *Config) *ndjsonNotifier {
+	return &ndjsonNotifier{enc: json.NewEncoder(w), cfg: cfg}
+}
+
+func (n *ndjsonNotifier) Name() string {
+	return "ndjson"
+}
+
+func (n *ndjsonNotifier) Notify(alert pendingAlert) error {
+	return n.enc.Encode(newNDJSONRecord(n.cfg, alert))
+}
+
+// newNDJSONRecord builds the machine-readable form of an alert. Its key and
+// incident are redacted (see redactKey and redactIncident), since every
+// consumer of the record, from MQTT to SNS to stdout, is outside the process.
+func newNDJSONRecord(cfg *Config, alert pendingAlert) NDJSONRecord {
+	return NDJSONRecord{
+		Key:      redactKey(cfg, alert.incident, alert.key),
+		Severity: severityOf(cfg, alert.incident),
+		Time:     alert.parsedTime.Format(time.RFC3339),
+		Incident: redactIncident(cfg, alert.incident),
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withDefaultKeys gives cfg the default DEDUP_STRATEGY key function, so
+// tests see the timestamp+address keys real alerts carry.
+func withDefaultKeys(t *testing.T, cfg *Config) *Config {
+	t.Helper()
+	keyFor, err := newKeyFunc(dedupTimestampAddress, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.KeyFor = keyFor
+	return cfg
+}
+
+func TestNewNDJSONRecordRedacts(t *testing.T) {
+	cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}, NoMapFor: []string{"WELFARE"}})
+	tests := []struct {
+		name      string
+		problem   string
+		address   string
+		hasCoords bool
+		rawKey    bool
+	}{
+		{"plain", "MVC PI", "100 Main St", true, true},
+		{"redacted address", "ASSAULT", "XXX Main St", false, false},
+		{"map hidden", "WELFARE CHECK", "100 Main St", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incident := Incident{Problem: tt.problem, Address: "100 Main St", Lat: 35.78, Long: -78.64, Timestamp: "2025-09-26 08:01:02.000"}
+			alert := pendingAlert{
+				key:        cfg.KeyFor(incident),
+				incident:   incident,
+				parsedTime: time.Date(2025, 9, 26, 8, 1, 2, 0, time.UTC),
+			}
+			record := newNDJSONRecord(cfg, alert)
+			if record.Incident.Address != tt.address {
+				t.Errorf("address = %q, want %q", record.Incident.Address, tt.address)
+			}
+			if got := record.Incident.Lat != 0 || record.Incident.Long != 0; got != tt.hasCoords {
+				t.Errorf("has coordinates = %v, want %v", got, tt.hasCoords)
+			}
+			if got := record.Key == alert.key; got != tt.rawKey {
+				t.Errorf("key = %q, want raw key %v", record.Key, tt.rawKey)
+			}
+			if !tt.rawKey && !strings.HasPrefix(record.Key, "sha256:") {
+				t.Errorf("key = %q, want a sha256: hash", record.Key)
+			}
+			if alert.incident.Address != "100 Main St" {
+				t.Error("redaction modified the alert's own incident")
+			}
+		})
+	}
+}
+
+func TestNDJSONNotifierRedacts(t *testing.T) {
+	cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}})
+	var out bytes.Buffer
+	n := newNDJSONNotifier(&out, cfg)
+	incidents := []Incident{
+		{Problem: "ASSAULT", Address: "12 Elm St", Lat: 35.7, Long: -78.6, Timestamp: "2025-09-26 07:01:02.000"},
+		{Problem: "MVC PI", Address: "100 Main St", Lat: 35.78, Long: -78.64, Timestamp: "2025-09-26 08:01:02.000"},
+	}
+	for _, incident := range incidents {
+		if err := n.Notify(pendingAlert{key: cfg.KeyFor(incident), incident: incident}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key     string
+		address string
+		lat     float64
+		leak    string
+	}{
+		{redactKey(cfg, incidents[0], cfg.KeyFor(incidents[0])), "XXX Elm St", 0, "12 Elm St"},
+		{"2025-09-26 08:01:02.000 100 Main St", "100 Main St", 35.78, ""},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(tests) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(tests))
+	}
+	for i, tt := range tests {
+		var record NDJSONRecord
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatalf("decoding line %d: %s", i, err)
+		}
+		if record.Key != tt.key || record.Incident.Address != tt.address || record.Incident.Lat != tt.lat {
+			t.Errorf("line %d = %+v, want key %q address %q lat %v", i, record, tt.key, tt.address, tt.lat)
+		}
+		if tt.leak != "" && strings.Contains(lines[i], tt.leak) {
+			t.Errorf("line %d leaks %q: %s", i, tt.leak, lines[i])
+		}
+	}
+}
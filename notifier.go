@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Notifier delivers alerts to a destination alongside the Discord routes.
+type Notifier interface {
+	// Name identifies the notifier in logs.
+	Name() string
+	// Notify delivers one alert.
+	Notify(alert pendingAlert) error
+}
+
+// alertSummary is the one-line plain text form of an alert used by the
+// text-only notifiers.
+func alertSummary(cfg *Config, alert pendingAlert) string {
+	incident := alert.incident
+	return fmt.Sprintf("%s at %s (%s), %s", displayProblem(cfg, incident.Problem),
+		displayAddress(cfg, incident), incident.Jurisdiction, alert.parsedTime.Format("Mon Jan 2, 3:04 PM MST"))
+}
+
+// notifyAll sends an alert to every configured notifier, logging failures
+// without affecting the Discord delivery.
+func (a *App) notifyAll(alert pendingAlert) {
+	for _, notifier := range a.notifiers {
+		if err := notifier.Notify(alert); err != nil {
+			log.Printf("Error sending %q to %s: %s", alert.key, notifier.Name(), err)
+			a.errs.add(errNotify)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// overviewDescriptionLimit keeps the incident list under Discord's 4096-character description limit.
+const overviewDescriptionLimit = 4000
+
+// overviewState remembers which webhook message holds the overview.
+type overviewState struct {
+	MessageID string `json:"message_id"`
+}
+
+// updateOverview edits the overview message to list the currently active
+// incidents, posting a new one when none exists or the old one was deleted.
+func (a *App) updateOverview(active []Incident) {
+	cfg := a.cfg
+	var state overviewState
+	if data, err := os.ReadFile(cfg.OverviewStateFilename); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			log.Printf("Error reading overview state, posting a new overview: %s", err)
+		}
+	}
+
+	payload, err := json.Marshal(DiscordWebhookPayload{
+		Username: "RWECC MVC Bot",
+		Embeds:   []DiscordEmbed{buildOverviewEmbed(cfg, active)},
+	})
+	if err != nil {
+		log.Printf("Error creating overview payload: %s", err)
+		return
+	}
+
+	if state.MessageID != "" {
+		status, err := a.editWebhookMessage(cfg.OverviewWebhookURL, state.MessageID, payload)
+		if err == nil {
+			return
+		}
+		if status != http.StatusNotFound {
+			log.Printf("Error editing overview message: %s", err)
+			return
+		}
+		log.Println("Overview message no longer exists, posting a new one")
+	}
+
+	postURL, err := withWait(cfg.OverviewWebhookURL)
+	if err != nil {
+		log.Printf("Error parsing OVERVIEW_WEBHOOK: %s", err)
+		return
+	}
+	resp, err := a.client.Post(postURL, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		log.Printf("Error posting overview: %s", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("Overview webhook returned non-2xx status: %s", resp.Status)
+		return
+	}
+	var msg DiscordMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		log.Printf("Error decoding overview response: %s", err)
+		return
+	}
+
+	data, err := json.Marshal(overviewState{MessageID: msg.ID})
+	if err == nil {
+		err = os.WriteFile(cfg.OverviewStateFilename, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error saving overview state: %s", err)
+	}
+}
+
+// editWebhookMessage PATCHes a message previously posted through webhookURL,
+// returning the HTTP status so callers can detect a deleted message.
+func (a *App) editWebhookMessage(webhookURL, messageID string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPatch, strings.TrimRight(webhookURL, "/")+"/messages/"+messageID, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned non-2xx status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// buildOverviewEmbed renders the active incidents as a bullet list with a
+// map marking each one.
+func buildOverviewEmbed(cfg *Config, active []Incident) DiscordEmbed {
+	embed := DiscordEmbed{
+		Title:     fmt.Sprintf("Active incidents (%d)", len(active)),
+		Color:     colorDefault,
+		Footer:    EmbedFooter{Text: footerText(cfg)},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if len(active) == 0 {
+		embed.Description = "No active incidents."
+		return embed
+	}
+
+	var b strings.Builder
+	var points []LatLng
+	for i, incident := range active {
+		line := fmt.Sprintf("• **%s** — %s (%s)\n", displayProblem(cfg, incident.Problem), displayAddress(cfg, incident), incident.Jurisdiction)
+		if b.Len()+len(line) > overviewDescriptionLimit {
+			fmt.Fprintf(&b, "…and %d more", len(active)-i)
+			break
+		}
+		b.WriteString(line)
+		if (incident.Lat != 0 || incident.Long != 0) && !mapHidden(cfg, incident) {
+			points = append(points, LatLng{Lat: incident.Lat, Long: incident.Long})
+		}
+	}
+	embed.Description = b.String()
+
+	if cfg.MapsAPIKey != "" && len(points) > 0 {
+		embed.Image = &EmbedImage{URL: buildMapURL(points, cfg.MapsAPIKey, cfg.MapType)}
+	}
+	return embed
+}
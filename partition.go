@@ -0,0 +1,23 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isDateTemplate reports whether a filename contains date placeholders.
+func isDateTemplate(filename string) bool {
+	return strings.Contains(filename, "%Y") || strings.Contains(filename, "%m") || strings.Contains(filename, "%d")
+}
+
+// expandDateTemplate fills a filename's %Y, %m and %d placeholders from t,
+// e.g. "incidents-%Y-%m.json" becomes "incidents-2024-06.json".
+func expandDateTemplate(filename string, t time.Time) string {
+	return strings.NewReplacer("%Y", t.Format("2006"), "%m", t.Format("01"), "%d", t.Format("02")).Replace(filename)
+}
+
+// dateTemplateGlob matches every partition a date template can expand to.
+func dateTemplateGlob(filename string) string {
+	return strings.NewReplacer("%Y", "[0-9][0-9][0-9][0-9]", "%m", "[0-9][0-9]", "%d", "[0-9][0-9]").Replace(filepath.Clean(filename))
+}
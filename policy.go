@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the severity routing block read from the --config file:
+//
+//	webhooks:
+//	  urgent: https://discord.com/api/webhooks/...
+//	  traffic: https://discord.com/api/webhooks/...
+//	severities:
+//	  injury:
+//	    destinations: [urgent]
+//	    mentions: ["123456789012345678"]
+//	  damage:
+//	    destinations: [traffic]
+//	  other:
+//	    destinations: []
+//
+// Each severity lists named webhooks and the role IDs to mention. A
+// severity with no destinations sends nothing to Discord; one left out of
+// the file goes to RWECC_DISCORD_HOOK as before. An optional top-level
+// filters string, in INCIDENT_FILTERS syntax, replaces INCIDENT_FILTERS.
+type Policy struct {
+	Webhooks   map[string]string           `yaml:"webhooks"`
+	Severities map[Severity]SeverityPolicy `yaml:"severities"`
+	Filters    string                      `yaml:"filters"`
+	// filterExpr is Filters parsed, or nil when it is empty.
+	filterExpr filterExpr
+}
+
+// SeverityPolicy is where one severity's alerts go and who they ping.
+type SeverityPolicy struct {
+	Destinations []string `yaml:"destinations"`
+	Mentions     []string `yaml:"mentions"`
+}
+
+// loadPolicy reads and validates a --config file.
+func loadPolicy(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parsePolicy(data, filename)
+}
+
+// parsePolicy decodes and validates --config contents; name labels errors.
+func parsePolicy(data []byte, name string) (*Policy, error) {
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if strings.TrimSpace(policy.Filters) != "" {
+		expr, err := parseFilterExpr(policy.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("%s: filters: %w", name, err)
+		}
+		policy.filterExpr = expr
+	}
+	return &policy, nil
+}
+
+// validate checks severity names, that every destination names a defined
+// webhook, and that mentions are Discord role IDs.
+func (p *Policy) validate() error {
+	for name, webhookURL := range p.Webhooks {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %q: invalid URL %q", name, webhookURL)
+		}
+	}
+	if len(p.Severities) == 0 {
+		return errors.New("no severities configured")
+	}
+	for severity, sp := range p.Severities {
+		if !slices.Contains(allSeverities, severity) {
+			return fmt.Errorf("unknown severity %q, want injury, damage or other", severity)
+		}
+		for _, name := range sp.Destinations {
+			if _, ok := p.Webhooks[name]; !ok {
+				return fmt.Errorf("severity %s: destination %q is not a defined webhook", severity, name)
+			}
+		}
+		for _, role := range sp.Mentions {
+			if !validRoleID(role) {
+				return fmt.Errorf("severity %s: mention %q is not a role ID", severity, role)
+			}
+		}
+	}
+	return nil
+}
+
+// policyFor returns the policy entry for an incident's severity, if any.
+func policyFor(cfg *Config, incident Incident) (SeverityPolicy, bool) {
+	if cfg.Policy == nil {
+		return SeverityPolicy{}, false
+	}
+	sp, ok := cfg.Policy.Severities[severityOf(cfg, incident)]
+	return sp, ok
+}
+
+// mentionsFor returns the role IDs an incident's alert should ping. A
+// --config entry for the severity decides; otherwise PING_ROLES are pinged
+// for the severities in PING_ON_SEVERITIES.
+func mentionsFor(cfg *Config, incident Incident) []string {
+	if sp, ok := policyFor(cfg, incident); ok {
+		return sp.Mentions
+	}
+	if slices.Contains(cfg.PingOnSeverities, severityOf(cfg, incident)) {
+		return cfg.PingRoles
+	}
+	return nil
+}
+
+// validRoleID reports whether s looks like a Discord role snowflake.
+func validRoleID(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMentionsFor(t *testing.T) {
+	policy, err := parsePolicy([]byte(`
+webhooks:
+  urgent: https://discord.com/api/webhooks/1/a
+severities:
+  injury:
+    destinations: [urgent]
+    mentions: ["111"]
+  damage:
+    destinations: [urgent]
+`), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	injury := Incident{Problem: "MVC INJURY"}
+	damage := Incident{Problem: "MVC DAMAGE"}
+	other := Incident{Problem: "MVC"}
+	tests := []struct {
+		name     string
+		cfg      *Config
+		incident Incident
+		want     []string
+	}{
+		{"no roles", &Config{PingOnSeverities: []Severity{SeverityInjury}}, injury, nil},
+		{"default injury", &Config{PingRoles: []string{"222"}, PingOnSeverities: []Severity{SeverityInjury}}, injury, []string{"222"}},
+		{"default skips damage", &Config{PingRoles: []string{"222"}, PingOnSeverities: []Severity{SeverityInjury}}, damage, nil},
+		{"damage and other", &Config{PingRoles: []string{"222", "333"}, PingOnSeverities: []Severity{SeverityDamage, SeverityOther}}, other, []string{"222", "333"}},
+		{"policy entry decides", &Config{Policy: policy, PingRoles: []string{"222"}, PingOnSeverities: allSeverities}, injury, []string{"111"}},
+		{"policy entry without mentions", &Config{Policy: policy, PingRoles: []string{"222"}, PingOnSeverities: allSeverities}, damage, nil},
+		{"severity missing from policy", &Config{Policy: policy, PingRoles: []string{"222"}, PingOnSeverities: allSeverities}, other, []string{"222"}},
+	}
+	for _, tt := range tests {
+		if got := mentionsFor(tt.cfg, tt.incident); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: mentionsFor = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigPingSettings(t *testing.T) {
+	tests := []struct {
+		roles, severities string
+		want              []Severity
+		wantErr           string
+	}{
+		{"", "", []Severity{SeverityInjury}, ""},
+		{"123,456", "Injury, damage", []Severity{SeverityInjury, SeverityDamage}, ""},
+		{"@here", "", nil, "not a role ID"},
+		{"123", "critical", nil, "unknown severity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.roles+"/"+tt.severities, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("PING_ROLES", tt.roles)
+			t.Setenv("PING_ON_SEVERITIES", tt.severities)
+			cfg, err := loadConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cfg.PingOnSeverities, tt.want) {
+				t.Errorf("PingOnSeverities = %v, want %v", cfg.PingOnSeverities, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// daemonMode reports whether the process polls repeatedly rather than
+// running a single cycle.
+func daemonMode(cfg *Config) bool {
+	return cfg.PollInterval > 0 || cfg.PollCron != nil
+}
+
+// adaptiveQuietCycles is how many consecutive quiet or failed cycles pass
+// before adaptive polling starts backing off.
+const adaptiveQuietCycles = 3
+
+// pollBackoff tracks the daemon's poll interval under ADAPTIVE_POLL. The
+// interval doubles for every quiet or failed cycle past adaptiveQuietCycles,
+// up to max, and snaps back to min as soon as a cycle sends an alert.
+type pollBackoff struct {
+	min, max time.Duration
+	interval time.Duration
+	quiet    int
+}
+
+// newPollBackoff starts at the minimum interval.
+func newPollBackoff(min, max time.Duration) *pollBackoff {
+	return &pollBackoff{min: min, max: max, interval: min}
+}
+
+// Next records the outcome of a cycle and returns how long to sleep.
+func (p *pollBackoff) Next(active bool) time.Duration {
+	previous := p.interval
+	if active {
+		p.quiet = 0
+		p.interval = p.min
+	} else {
+		p.quiet++
+		if p.quiet >= adaptiveQuietCycles {
+			p.interval *= 2
+			if p.interval > p.max {
+				p.interval = p.max
+			}
+		}
+	}
+	if p.interval != previous {
+		log.Printf("Adaptive polling: interval now %s", p.interval)
+	}
+	return p.interval
+}
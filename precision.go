@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// coordDecimals counts the significant decimal places of a coordinate.
+// Trailing zeros are not significant, so 35.780000 counts as 2.
+func coordDecimals(v float64) int {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if _, frac, ok := strings.Cut(s, "."); ok {
+		return len(frac)
+	}
+	return 0
+}
+
+// formatCoords renders a lat/long pair for display, rounded to
+// COORD_DISPLAY_DECIMALS. Map URLs keep full precision.
+func formatCoords(cfg *Config, lat, long float64) string {
+	return fmt.Sprintf("%.*f, %.*f", cfg.CoordDisplayDecimals, lat, cfg.CoordDisplayDecimals, long)
+}
+
+// coordsCoarse reports whether an incident's coordinates look rounded to
+// fewer than MIN_COORD_PRECISION decimals. Both lat and long must be short,
+// since a precise coordinate ends in zero now and then. Incidents without
+// coordinates are not judged.
+func coordsCoarse(cfg *Config, incident Incident) bool {
+	if cfg.MinCoordPrecision == 0 || (incident.Lat == 0 && incident.Long == 0) {
+		return false
+	}
+	return coordDecimals(incident.Lat) < cfg.MinCoordPrecision && coordDecimals(incident.Long) < cfg.MinCoordPrecision
+}
+
+// precisionFilter drops incidents with coarse coordinates when
+// COARSE_COORDS_MODE is skip.
+type precisionFilter struct {
+	cfg *Config
+}
+
+func (f precisionFilter) Keep(incident Incident) (bool, string) {
+	if coordsCoarse(f.cfg, incident) {
+		return false, fmt.Sprintf("coordinates have fewer than %d decimals (MIN_COORD_PRECISION)", f.cfg.MinCoordPrecision)
+	}
+	return true, ""
+}
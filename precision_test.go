@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestCoordDecimals(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want int
+	}{
+		{35, 0},
+		{35.7, 1},
+		{35.78, 2},
+		{35.780000, 2},
+		{-78.638212, 6},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := coordDecimals(tt.v); got != tt.want {
+			t.Errorf("coordDecimals(%v) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestCoordsCoarse(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		lat, long float64
+		want      bool
+	}{
+		{"disabled", 0, 35.7, -78.6, false},
+		{"precise", 4, 35.7796, -78.6382, false},
+		{"both rounded", 4, 35.78, -78.64, true},
+		{"one ends in zero", 4, 35.78, -78.6382, false},
+		{"no coordinates", 4, 0, 0, false},
+		{"exactly the minimum", 3, 35.779, -78.638, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{MinCoordPrecision: tt.precision, CoarseCoordsMode: "skip"}
+		incident := Incident{Lat: tt.lat, Long: tt.long}
+		if got := coordsCoarse(cfg, incident); got != tt.want {
+			t.Errorf("%s: coordsCoarse = %v, want %v", tt.name, got, tt.want)
+		}
+		if keep, _ := (precisionFilter{cfg: cfg}).Keep(incident); keep == tt.want {
+			t.Errorf("%s: precisionFilter kept = %v", tt.name, keep)
+		}
+	}
+}
+
+func TestBuildFiltersCoarseMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{"skip", 2},
+		{"flag", 1},
+	}
+	for _, tt := range tests {
+		cfg := &Config{MinCoordPrecision: 4, CoarseCoordsMode: tt.mode}
+		if got := len(buildFilters(cfg)); got != tt.want {
+			t.Errorf("%s: %d filters, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
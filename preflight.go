@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// preflightTimeout bounds each host's lookup and connection attempt.
+const preflightTimeout = 5 * time.Second
+
+// preflightTargets returns the configured URLs worth checking, by the
+// setting they came from.
+func preflightTargets(cfg *Config) map[string]string {
+	targets := discordWebhooks(cfg)
+	if _, ok := feedPath(cfg.APIURL); !ok {
+		targets["RWECC_URL"] = cfg.APIURL
+	}
+	if cfg.GenericWebhookURL != "" {
+		targets["GENERIC_WEBHOOK_URL"] = cfg.GenericWebhookURL
+	}
+	if cfg.MatrixHomeserver != "" {
+		targets["MATRIX_HOMESERVER"] = cfg.MatrixHomeserver
+	}
+	return targets
+}
+
+// runPreflight checks that each configured host resolves and accepts a TCP
+// connection, logging what failed so a bad URL can be told apart from a
+// network outage. It never exits: the daemon keeps going and recovers once
+// connectivity returns.
+func runPreflight(cfg *Config) {
+	targets := preflightTargets(cfg)
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolver := net.DefaultResolver
+	if cfg.DNSResolver != "" {
+		resolver = newTransportResolver(cfg)
+	}
+
+	failures := 0
+	checked := map[string]bool{}
+	for _, name := range names {
+		u, err := url.Parse(targets[name])
+		if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Printf("Preflight: %s is not a valid http(s) URL; check the setting", name)
+			failures++
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+		}
+		hostPort := net.JoinHostPort(u.Hostname(), port)
+		if checked[hostPort] {
+			continue
+		}
+		checked[hostPort] = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+		addrs, err := resolver.LookupHost(ctx, u.Hostname())
+		if err != nil {
+			cancel()
+			log.Printf("Preflight: cannot resolve %s for %s (misspelled host, or DNS is down): %s", u.Hostname(), name, err)
+			failures++
+			continue
+		}
+		dialer := net.Dialer{Resolver: resolver}
+		if name == "RWECC_URL" && cfg.BindAddr != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: cfg.BindAddr}
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", hostPort)
+		cancel()
+		if err != nil {
+			log.Printf("Preflight: %s resolves to %s but %s is unreachable (network down or firewalled?): %s",
+				u.Hostname(), strings.Join(addrs, ", "), name, err)
+			failures++
+			continue
+		}
+		conn.Close()
+	}
+
+	if failures > 0 {
+		log.Printf("Preflight: %d problems found; continuing, and fetches will be retried each cycle", failures)
+	} else {
+		log.Printf("Preflight: all %d hosts resolve and are reachable", len(checked))
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// COORD_CRS names the EPSG code of a feed that publishes projected
+// coordinates. The projected easting (x) is read from the incident's long
+// field and the northing (y) from lat, as ArcGIS geometry is, and both are
+// replaced with WGS84 degrees right after decoding. NAD83 is treated as
+// WGS84; the two differ by about a metre, well below map resolution.
+
+// usSurveyFoot is the US survey foot in metres.
+const usSurveyFoot = 1200.0 / 3937.0
+
+// ellipsoid is a reference ellipsoid given by its semi-major axis in metres
+// and inverse flattening.
+type ellipsoid struct {
+	a, invF float64
+}
+
+var (
+	grs80 = ellipsoid{a: 6378137, invF: 298.257222101}
+	wgs84 = ellipsoid{a: 6378137, invF: 298.257223563}
+)
+
+// e2 is the first eccentricity squared.
+func (el ellipsoid) e2() float64 {
+	f := 1 / el.invF
+	return f * (2 - f)
+}
+
+// projection converts projected coordinates in metres to latitude and
+// longitude in degrees.
+type projection interface {
+	toLatLng(x, y float64) (lat, long float64)
+}
+
+// crs is a supported coordinate reference system: its projection and the
+// size of its linear unit in metres.
+type crs struct {
+	proj projection
+	unit float64
+}
+
+// toLatLng reprojects a point given in the system's own units.
+func (c crs) toLatLng(x, y float64) (float64, float64) {
+	return c.proj.toLatLng(x*c.unit, y*c.unit)
+}
+
+// ncStatePlane is the NAD83 North Carolina state plane projection, which
+// the Wake County feeds use when they are not in lat/long.
+func ncStatePlane() projection {
+	return newLambertConic(grs80, 36+10.0/60, 34+20.0/60, 33.75, -79, 609601.22, 0)
+}
+
+// parseCRS looks up a COORD_CRS value such as "EPSG:2264" or "2264".
+func parseCRS(value string) (*crs, error) {
+	code, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "EPSG:"))
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an EPSG code", value)
+	}
+	switch {
+	case code == 4326:
+		return nil, nil
+	case code == 2264:
+		return &crs{proj: ncStatePlane(), unit: usSurveyFoot}, nil
+	case code == 32119 || code == 3358:
+		return &crs{proj: ncStatePlane(), unit: 1}, nil
+	case code == 3857:
+		return &crs{proj: webMercator{}, unit: 1}, nil
+	case code >= 26901 && code <= 26923:
+		return &crs{proj: newUTM(grs80, code-26900, false), unit: 1}, nil
+	case code >= 32601 && code <= 32660:
+		return &crs{proj: newUTM(wgs84, code-32600, false), unit: 1}, nil
+	case code >= 32701 && code <= 32760:
+		return &crs{proj: newUTM(wgs84, code-32700, true), unit: 1}, nil
+	}
+	return nil, fmt.Errorf("EPSG:%d is not supported; use 2264, 32119, 3358, 3857, or a UTM zone (269xx, 326xx, 327xx)", code)
+}
+
+// reprojectIncidents converts each incident's coordinates to WGS84 in place.
+// Incidents without coordinates are left at 0,0.
+func reprojectIncidents(c *crs, incidents []Incident) {
+	for i := range incidents {
+		if incidents[i].Lat == 0 && incidents[i].Long == 0 {
+			continue
+		}
+		incidents[i].Lat, incidents[i].Long = c.toLatLng(incidents[i].Long, incidents[i].Lat)
+	}
+}
+
+// lambertConic is the Lambert Conformal Conic projection with two standard
+// parallels (EPSG method 9802), inverted per Snyder, "Map Projections: A
+// Working Manual", pp. 107-109.
+type lambertConic struct {
+	a, e         float64
+	n, f, rho0   float64
+	lon0         float64
+	falseEasting float64
+	falseNorth   float64
+}
+
+// newLambertConic takes the standard parallels, the origin and the false
+// origin in degrees and metres.
+func newLambertConic(el ellipsoid, lat1, lat2, lat0, lon0, falseEasting, falseNorthing float64) *lambertConic {
+	e := math.Sqrt(el.e2())
+	m := func(phi float64) float64 {
+		s := e * math.Sin(phi)
+		return math.Cos(phi) / math.Sqrt(1-s*s)
+	}
+	t := func(phi float64) float64 {
+		s := e * math.Sin(phi)
+		return math.Tan(math.Pi/4-phi/2) / math.Pow((1-s)/(1+s), e/2)
+	}
+	phi1, phi2, phi0 := radians(lat1), radians(lat2), radians(lat0)
+	n := (math.Log(m(phi1)) - math.Log(m(phi2))) / (math.Log(t(phi1)) - math.Log(t(phi2)))
+	f := m(phi1) / (n * math.Pow(t(phi1), n))
+	return &lambertConic{
+		a: el.a, e: e, n: n, f: f,
+		rho0:         el.a * f * math.Pow(t(phi0), n),
+		lon0:         radians(lon0),
+		falseEasting: falseEasting,
+		falseNorth:   falseNorthing,
+	}
+}
+
+func (p *lambertConic) toLatLng(x, y float64) (float64, float64) {
+	dx, dy := x-p.falseEasting, p.rho0-(y-p.falseNorth)
+	sign := math.Copysign(1, p.n)
+	rho := sign * math.Hypot(dx, dy)
+	theta := math.Atan2(sign*dx, sign*dy)
+	t := math.Pow(rho/(p.a*p.f), 1/p.n)
+
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for range 10 {
+		s := p.e * math.Sin(phi)
+		phi = math.Pi/2 - 2*math.Atan(t*math.Pow((1-s)/(1+s), p.e/2))
+	}
+	return degrees(phi), degrees(theta/p.n + p.lon0)
+}
+
+// transverseMercator is the Transverse Mercator projection (EPSG method
+// 9807), inverted per Snyder pp. 60-64.
+type transverseMercator struct {
+	a, e2, k0     float64
+	lat0, lon0    float64
+	falseEasting  float64
+	falseNorthing float64
+}
+
+// newUTM returns the projection for a UTM zone.
+func newUTM(el ellipsoid, zone int, south bool) *transverseMercator {
+	p := &transverseMercator{
+		a: el.a, e2: el.e2(), k0: 0.9996,
+		lon0:         radians(float64(zone*6 - 183)),
+		falseEasting: 500000,
+	}
+	if south {
+		p.falseNorthing = 10000000
+	}
+	return p
+}
+
+// meridianArc is the distance along the meridian from the equator to phi.
+func (p *transverseMercator) meridianArc(phi float64) float64 {
+	e2, e4, e6 := p.e2, p.e2*p.e2, p.e2*p.e2*p.e2
+	return p.a * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+func (p *transverseMercator) toLatLng(x, y float64) (float64, float64) {
+	e2 := p.e2
+	ep2 := e2 / (1 - e2)
+	m := p.meridianArc(p.lat0) + (y-p.falseNorthing)/p.k0
+	mu := m / (p.a * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := ep2 * cos1 * cos1
+	t1 := tan1 * tan1
+	n1 := p.a / math.Sqrt(1-e2*sin1*sin1)
+	r1 := p.a * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := (x - p.falseEasting) / (n1 * p.k0)
+
+	phi := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lambda := p.lon0 + (d-
+		(1+2*t1+c1)*math.Pow(d, 3)/6+
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120)/cos1
+	return degrees(phi), degrees(lambda)
+}
+
+// webMercator is the spherical Pseudo-Mercator used by web maps (EPSG:3857).
+type webMercator struct{}
+
+func (webMercator) toLatLng(x, y float64) (float64, float64) {
+	const r = 6378137
+	return degrees(2*math.Atan(math.Exp(y/r)) - math.Pi/2), degrees(x / r)
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
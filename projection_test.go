@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCRSToLatLng(t *testing.T) {
+	// The EPSG:2264 points were projected forward independently (EPSG
+	// Guidance Note 7-2, method 9802); the others are projection origins.
+	tests := []struct {
+		name      string
+		code      string
+		x, y      float64
+		lat, long float64
+	}{
+		{"2264 false origin", "EPSG:2264", 2000000, 0, 33.75, -79},
+		{"2264 Raleigh", "EPSG:2264", 2107312.433, 738866.607, 35.7796, -78.6382},
+		{"2264 Charlotte", "2264", 1449620.399, 542689.071, 35.2271, -80.8431},
+		{"2264 Outer Banks", "epsg:2264", 3028667.271, 1019065.869, 36.5, -75.5},
+		{"32119 Raleigh", "EPSG:32119", 642310.114, 225206.992, 35.7796, -78.6382},
+		{"3857 Raleigh", "EPSG:3857", -8753964.381, 4270336.897, 35.7796, -78.6382},
+		{"UTM 17N origin", "EPSG:26917", 500000, 0, 0, -81},
+		{"UTM 17S origin", "EPSG:32717", 500000, 10000000, 0, -81},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCRS(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lat, long := c.toLatLng(tt.x, tt.y)
+			// 1e-6 degrees is about 10 cm.
+			if math.Abs(lat-tt.lat) > 1e-6 || math.Abs(long-tt.long) > 1e-6 {
+				t.Errorf("toLatLng(%v, %v) = %.7f, %.7f, want %.7f, %.7f", tt.x, tt.y, lat, long, tt.lat, tt.long)
+			}
+		})
+	}
+}
+
+func TestParseCRS(t *testing.T) {
+	tests := []struct {
+		value    string
+		identity bool
+		wantErr  bool
+	}{
+		{"EPSG:4326", true, false},
+		{" 4326 ", true, false},
+		{"EPSG:2264", false, false},
+		{"EPSG:3358", false, false},
+		{"EPSG:26923", false, false},
+		{"EPSG:32760", false, false},
+		{"EPSG:26924", false, true},
+		{"EPSG:27700", false, true},
+		{"NAD83", false, true},
+	}
+	for _, tt := range tests {
+		c, err := parseCRS(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCRS(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (c == nil) != tt.identity {
+			t.Errorf("parseCRS(%q) = %v, want identity %v", tt.value, c, tt.identity)
+		}
+	}
+}
+
+func TestReprojectIncidents(t *testing.T) {
+	c, err := parseCRS("EPSG:2264")
+	if err != nil {
+		t.Fatal(err)
+	}
+	incidents := []Incident{
+		{Lat: 738866.607, Long: 2107312.433},
+		{},
+	}
+	reprojectIncidents(c, incidents)
+	if math.Abs(incidents[0].Lat-35.7796) > 1e-6 || math.Abs(incidents[0].Long+78.6382) > 1e-6 {
+		t.Errorf("reprojected to %v, %v", incidents[0].Lat, incidents[0].Long)
+	}
+	if incidents[1].Lat != 0 || incidents[1].Long != 0 {
+		t.Errorf("incident without coordinates moved to %v, %v", incidents[1].Lat, incidents[1].Long)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pushMetrics sends this job's metrics to the Pushgateway, so one-shot runs
+// that are never scraped are still observable.
+//
+// A daemon replaces the group (PUT) with the same metrics /metrics serves;
+// its counters grow for the life of the process, as Prometheus expects. A
+// one-shot run's counters would restart from zero every run, so it pushes
+// gauges for that run instead (see writeRunGauges), with POST so the last
+// success time from an earlier run survives a failed one.
+func pushMetrics(client *http.Client, cfg *Config, m *metrics, oneShot bool) error {
+	var body bytes.Buffer
+	method := http.MethodPut
+	m.mu.Lock()
+	if oneShot {
+		method = http.MethodPost
+		m.writeOneShotMetrics(&body)
+	} else {
+		m.writeMetrics(&body)
+	}
+	m.mu.Unlock()
+
+	target := strings.TrimRight(cfg.PushgatewayURL, "/") + "/metrics/job/" + url.PathEscape(cfg.PushgatewayJob)
+	req, err := http.NewRequest(method, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// validHTTPURL checks a URL setting, such as PUSHGATEWAY_URL, is an
+// http(s) URL with a host.
+func validHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q, want http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
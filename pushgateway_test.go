@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		oneShot bool
+		method  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "one-shot",
+			oneShot: true,
+			method:  http.MethodPost,
+			want:    []string{"rwecc_active_incidents 1", "rwecc_last_run_alerts_sent 2", "rwecc_last_run_success 1", "rwecc_last_run_timestamp_seconds "},
+			notWant: []string{"_total"},
+		},
+		{
+			name:    "daemon",
+			method:  http.MethodPut,
+			want:    []string{"rwecc_active_incidents 1", "rwecc_alerts_sent_total 2", "rwecc_cycles_total 1"},
+			notWant: []string{"rwecc_last_run_"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path, body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				method, path, body = r.Method, r.URL.Path, string(data)
+			}))
+			defer srv.Close()
+
+			m := &metrics{}
+			m.recordCycle([]Incident{{Problem: "MVC PI"}}, 2)
+			cfg := &Config{PushgatewayURL: srv.URL + "/", PushgatewayJob: "911 reporting"}
+			if err := pushMetrics(srv.Client(), cfg, m, tt.oneShot); err != nil {
+				t.Fatal(err)
+			}
+			if method != tt.method {
+				t.Errorf("method = %s, want %s", method, tt.method)
+			}
+			if path != "/metrics/job/911 reporting" {
+				t.Errorf("path = %q", path)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("body is missing %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(body, s) {
+					t.Errorf("body has %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
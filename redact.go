@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// streetNumberRe matches a leading house number such as "1234", "12A" or "100-120".
+var streetNumberRe = regexp.MustCompile(`^\s*\d+[A-Za-z]?(-\d+[A-Za-z]?)?\s+`)
+
+// addressRedacted reports whether the incident's problem matches REDACT_ADDRESS_FOR.
+func addressRedacted(cfg *Config, incident Incident) bool {
+	for _, pattern := range cfg.RedactAddressFor {
+		if containsFold(incident.Problem, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapHidden reports whether an incident must not appear on any map or map
+// link: its address is redacted, or its problem matches NO_MAP_FOR.
+func mapHidden(cfg *Config, incident Incident) bool {
+	if addressRedacted(cfg, incident) {
+		return true
+	}
+	for _, pattern := range cfg.NoMapFor {
+		if containsFold(incident.Problem, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// thumbnailWanted reports whether MAP_FOR_JURISDICTIONS allows a map
+// thumbnail for the incident's jurisdiction. An empty list allows all.
+func thumbnailWanted(cfg *Config, incident Incident) bool {
+	if len(cfg.MapForJurisdictions) == 0 {
+		return true
+	}
+	for _, jurisdiction := range cfg.MapForJurisdictions {
+		if strings.EqualFold(strings.TrimSpace(incident.Jurisdiction), jurisdiction) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayAddress returns the address to show for an incident. Redaction is
+// display-only; the raw address stays on the incident for dedup and archiving.
+func displayAddress(cfg *Config, incident Incident) string {
+	if !addressRedacted(cfg, incident) {
+		return incident.Address
+	}
+	return redactAddress(incident.Address)
+}
+
+// redactAddress swaps the street number for "XXX", leaving the street name.
+// Addresses without a leading number (intersections, landmarks) pass through.
+func redactAddress(address string) string {
+	loc := streetNumberRe.FindStringIndex(address)
+	if loc == nil {
+		return address
+	}
+	return "XXX " + address[loc[1]:]
+}
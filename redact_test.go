@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactAddress(t *testing.T) {
+	tests := []struct {
+		address, want string
+	}{
+		{"1234 Main St", "XXX Main St"},
+		{"12A Oak Ave", "XXX Oak Ave"},
+		{"100-120 Hillsborough St", "XXX Hillsborough St"},
+		{"  55 Elm St", "XXX Elm St"},
+		{"Main St / Oak Ave", "Main St / Oak Ave"},
+		{"I-40 EB", "I-40 EB"},
+		{"1234", "1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := redactAddress(tt.address); got != tt.want {
+			t.Errorf("redactAddress(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayAddressAndMapHidden(t *testing.T) {
+	cfg := &Config{RedactAddressFor: []string{"assault", "DOMESTIC"}, NoMapFor: []string{"WELFARE"}}
+	tests := []struct {
+		problem   string
+		address   string
+		mapHidden bool
+	}{
+		{"MVC PI", "100 Main St", false},
+		{"ASSAULT IN PROGRESS", "XXX Main St", true},
+		{"Domestic Disturbance", "XXX Main St", true},
+		{"WELFARE CHECK", "100 Main St", true},
+	}
+	for _, tt := range tests {
+		incident := Incident{Problem: tt.problem, Address: "100 Main St"}
+		if got := displayAddress(cfg, incident); got != tt.address {
+			t.Errorf("%s: displayAddress = %q, want %q", tt.problem, got, tt.address)
+		}
+		if got := mapHidden(cfg, incident); got != tt.mapHidden {
+			t.Errorf("%s: mapHidden = %v, want %v", tt.problem, got, tt.mapHidden)
+		}
+	}
+}
+
+func TestRedactIncident(t *testing.T) {
+	cfg := &Config{RedactAddressFor: []string{"ASSAULT"}, NoMapFor: []string{"WELFARE"}}
+	tests := []struct {
+		problem   string
+		address   string
+		lat, long float64
+	}{
+		{"MVC PI", "100 Main St", 35.78, -78.64},
+		{"ASSAULT", "XXX Main St", 0, 0},
+		{"WELFARE CHECK", "100 Main St", 0, 0},
+	}
+	for _, tt := range tests {
+		got := redactIncident(cfg, Incident{Problem: tt.problem, Address: "100 Main St", Lat: 35.78, Long: -78.64})
+		if got.Address != tt.address || got.Lat != tt.lat || got.Long != tt.long {
+			t.Errorf("%s: got %q (%v, %v), want %q (%v, %v)", tt.problem, got.Address, got.Lat, got.Long, tt.address, tt.lat, tt.long)
+		}
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	cfg := &Config{RedactAddressFor: []string{"ASSAULT"}, NoMapFor: []string{"WELFARE"}}
+	key := "2025-09-26 08:01:02.000 12 Elm St"
+	tests := []struct {
+		problem string
+		raw     bool
+	}{
+		{"MVC PI", true},
+		{"WELFARE CHECK", true},
+		{"ASSAULT", false},
+	}
+	for _, tt := range tests {
+		incident := Incident{Problem: tt.problem, Address: "12 Elm St"}
+		got := redactKey(cfg, incident, key)
+		if (got == key) != tt.raw {
+			t.Errorf("%s: redactKey = %q, want raw %v", tt.problem, got, tt.raw)
+		}
+		if !tt.raw && (!strings.HasPrefix(got, "sha256:") || strings.Contains(got, "Elm") || got != redactKey(cfg, incident, key)) {
+			t.Errorf("%s: redactKey = %q, want a stable sha256: hash", tt.problem, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// maxConfigSize bounds a fetched --config, which is a few kilobytes of YAML.
+const maxConfigSize = 1 << 20
+
+// isRemoteConfig reports whether --config names an HTTP(S) URL rather than
+// a local file.
+func isRemoteConfig(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// loadPolicySource reads --config from a file or URL. A fetched config is
+// validated before it is cached in CONFIG_CACHE_FILE; if the fetch fails
+// and useCache is set, the last good copy is used instead, so a config
+// server outage does not stop a restart.
+func loadPolicySource(client *http.Client, cfg *Config, source string, useCache bool) (*Policy, error) {
+	if !isRemoteConfig(source) {
+		return loadPolicy(source)
+	}
+	data, err := fetchConfig(client, source)
+	if err == nil {
+		policy, err := parsePolicy(data, source)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(cfg.ConfigCacheFilename, data, 0600); err != nil {
+			log.Printf("Error caching --config: %s", err)
+		}
+		return policy, nil
+	}
+	if !useCache {
+		return nil, err
+	}
+	log.Printf("Warning: fetching --config failed, using the cached copy in %s: %s", cfg.ConfigCacheFilename, err)
+	policy, cacheErr := loadPolicy(cfg.ConfigCacheFilename)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("%w (and no usable cache: %s)", err, cacheErr)
+	}
+	return policy, nil
+}
+
+// fetchConfig downloads a remote --config.
+func fetchConfig(client *http.Client, source string) ([]byte, error) {
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("config server returned non-2xx status: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("config is larger than %d bytes", maxConfigSize)
+	}
+	return data, nil
+}
+
+// applyPolicy installs a --config policy. Its filters, if any, replace
+// INCIDENT_FILTERS; a policy without them restores the environment's.
+func applyPolicy(cfg *Config, policy *Policy, envFilters filterExpr) {
+	cfg.Policy = policy
+	cfg.IncidentFilters = envFilters
+	if policy.filterExpr != nil {
+		cfg.IncidentFilters = policy.filterExpr
+	}
+}
+
+// reloadPolicy re-reads --config and applies it only if it is valid; on
+// any error the running policy stays in place. It runs between cycles.
+func (a *App) reloadPolicy() {
+	policy, err := loadPolicySource(a.client, a.cfg, a.configSource, false)
+	if err != nil {
+		log.Printf("Error reloading --config, keeping the current one: %s", err)
+		return
+	}
+	applyPolicy(a.cfg, policy, a.envFilters)
+	a.filters = buildFilters(a.cfg)
+	log.Printf("Reloaded --config from %s", a.configSource)
+}
+
+// reloadOnHangup makes SIGHUP reload --config while the daemon sleeps.
+func (a *App) reloadOnHangup() {
+	a.reloads = make(chan os.Signal, 1)
+	signal.Notify(a.reloads, syscall.SIGHUP)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// replayLastAlerts re-posts the n most recently archived alerts, oldest
+// first, to the destinations they would be routed to today. Replays are
+// marked as such, never ping, and leave the dedup state and archive alone.
+func replayLastAlerts(client *http.Client, cfg *Config, n int) error {
+	records, err := readArchiveSince(cfg, time.Time{})
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	if len(records) == 0 {
+		log.Println("No archived alerts to replay.")
+		return nil
+	}
+
+	failed := 0
+	for _, record := range records {
+		alert := newPendingAlert(cfg, record.Key, record.Incident)
+		alert.firstSeen = record.FirstSeen
+		alert.replayOf = record.SentAt
+		for _, webhookURL := range destinationsFor(cfg, record.Incident) {
+			if _, err := sendToDiscord(client, cfg, webhookURL, alert); err != nil {
+				log.Printf("Error replaying %q: %s", record.Key, err)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d sends failed", failed)
+	}
+	log.Printf("Replayed %d alerts.", len(records))
+	return nil
+}
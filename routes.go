@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route sends incidents whose problem contains Pattern to WebhookURL.
+type Route struct {
+	Pattern    string
+	WebhookURL string
+}
+
+// parseRoutes parses ROUTES, a comma-separated list of pattern=webhook pairs
+// evaluated in order, e.g. "MVC=https://...,MEDICAL=https://...".
+func parseRoutes(value string) ([]Route, error) {
+	var routes []Route
+	for _, entry := range splitList(value) {
+		pattern, webhookURL, ok := strings.Cut(entry, "=")
+		pattern, webhookURL = strings.TrimSpace(pattern), strings.TrimSpace(webhookURL)
+		if !ok || pattern == "" || webhookURL == "" {
+			return nil, fmt.Errorf("invalid ROUTES entry %q, want pattern=webhook", entry)
+		}
+		routes = append(routes, Route{Pattern: pattern, WebhookURL: webhookURL})
+	}
+	return routes, nil
+}
+
+// destinationsFor resolves the webhooks an incident should be sent to. A
+// --config policy entry for the incident's severity decides on its own.
+// Otherwise, with ROUTE_MODE=first only the first matching route is used;
+// with "all", every matching route is. Incidents matching no route go to
+// RWECC_DISCORD_HOOK.
+func destinationsFor(cfg *Config, incident Incident) []string {
+	if sp, ok := policyFor(cfg, incident); ok {
+		destinations := make([]string, 0, len(sp.Destinations))
+		for _, name := range sp.Destinations {
+			destinations = append(destinations, cfg.Policy.Webhooks[name])
+		}
+		return destinations
+	}
+
+	var destinations []string
+	for _, route := range cfg.Routes {
+		if !containsFold(incident.Problem, route.Pattern) {
+			continue
+		}
+		destinations = append(destinations, route.WebhookURL)
+		if cfg.RouteMode == "first" {
+			break
+		}
+	}
+	if len(destinations) == 0 {
+		destinations = []string{cfg.WebhookURL}
+	}
+	return destinations
+}
+
+// containsFold is a case-insensitive strings.Contains.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
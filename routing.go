@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// routingClient estimates drive times from ROUTING_STATION with an
+// OSRM-compatible /route/v1/driving endpoint. Results are cached per ~100m
+// bucket, since road times barely change within one.
+type routingClient struct {
+	client   *http.Client
+	endpoint string
+	station  LatLng
+	cache    map[string]routeEstimate
+}
+
+// routeEstimate is one drive from the station.
+type routeEstimate struct {
+	duration time.Duration
+	miles    float64
+}
+
+// newRoutingClient returns a client for ROUTING_API_URL.
+func newRoutingClient(client *http.Client, endpoint string, station LatLng) *routingClient {
+	return &routingClient{client: client, endpoint: strings.TrimRight(endpoint, "/"), station: station, cache: make(map[string]routeEstimate)}
+}
+
+// parseStation reads ROUTING_STATION as "lat,long".
+func parseStation(value string) (LatLng, error) {
+	latText, longText, ok := strings.Cut(value, ",")
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	long, longErr := strconv.ParseFloat(strings.TrimSpace(longText), 64)
+	if !ok || latErr != nil || longErr != nil || lat < -90 || lat > 90 || long < -180 || long > 180 {
+		return LatLng{}, fmt.Errorf("invalid coordinates %q, want lat,long", value)
+	}
+	return LatLng{Lat: lat, Long: long}, nil
+}
+
+// DriveTime returns the estimated drive from the station to a point.
+func (r *routingClient) DriveTime(to LatLng) (routeEstimate, error) {
+	key := fmt.Sprintf("%.3f,%.3f", to.Lat, to.Long)
+	if estimate, ok := r.cache[key]; ok {
+		return estimate, nil
+	}
+
+	// OSRM takes long,lat pairs.
+	target := fmt.Sprintf("%s/route/v1/driving/%.6f,%.6f;%.6f,%.6f?overview=false",
+		r.endpoint, r.station.Long, r.station.Lat, to.Long, to.Lat)
+	resp, err := r.client.Get(target)
+	if err != nil {
+		return routeEstimate{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return routeEstimate{}, fmt.Errorf("routing API returned non-2xx status: %s", resp.Status)
+	}
+
+	var result struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Duration float64 `json:"duration"`
+			Distance float64 `json:"distance"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return routeEstimate{}, fmt.Errorf("decoding routing response: %w", err)
+	}
+	if result.Code != "Ok" || len(result.Routes) == 0 {
+		return routeEstimate{}, fmt.Errorf("no route found (code %q)", result.Code)
+	}
+
+	estimate := routeEstimate{
+		duration: time.Duration(result.Routes[0].Duration * float64(time.Second)),
+		miles:    result.Routes[0].Distance / metresPerMile,
+	}
+	r.cache[key] = estimate
+	return estimate, nil
+}
+
+// metresPerMile converts OSRM distances.
+const metresPerMile = 1609.344
+
+// String renders an estimate such as "12 min (8.4 mi)".
+func (e routeEstimate) String() string {
+	return fmt.Sprintf("%d min (%.1f mi)", max(int(e.duration.Round(time.Minute)/time.Minute), 1), e.miles)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// maxSchemaProblems caps how many validation problems are reported per fetch.
+const maxSchemaProblems = 20
+
+// incidentSchema lists the fields every incident must carry and the JSON
+// kind each must have. Coordinates may also arrive as numeric strings.
+var incidentSchema = []struct {
+	name string
+	kind string
+}{
+	{"jurisdiction", "string"},
+	{"problem", "string"},
+	{"address", "string"},
+	{"lat", "coordinate"},
+	{"long", "coordinate"},
+	{"timestamp", "string"},
+}
+
+// validateIncidentsJSON checks a raw API response against incidentSchema and
+// returns a description of each problem found.
+func validateIncidentsJSON(body []byte) []string {
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &records); err != nil {
+		return []string{fmt.Sprintf("response is not an array of objects: %s", err)}
+	}
+
+	var problems []string
+	for i, record := range records {
+		for _, field := range incidentSchema {
+			raw, ok := record[field.name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("incident %d: missing field %q", i, field.name))
+				continue
+			}
+			if !matchesKind(raw, field.kind) {
+				problems = append(problems, fmt.Sprintf("incident %d: field %q is %s, want %s", i, field.name, raw, field.kind))
+			}
+		}
+	}
+	return problems
+}
+
+// matchesKind reports whether a raw JSON value has the expected kind.
+func matchesKind(raw json.RawMessage, kind string) bool {
+	switch kind {
+	case "string":
+		var s string
+		return json.Unmarshal(raw, &s) == nil
+	case "coordinate":
+		var f float64
+		if json.Unmarshal(raw, &f) == nil {
+			return true
+		}
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return false
+		}
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	}
+	return false
+}
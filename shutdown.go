@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// drainOnSignal makes SIGINT and SIGTERM stop the daemon gracefully: the
+// current cycle finishes its in-flight send, skips the rest, and saves
+// state. If that takes longer than timeout, or a second signal arrives,
+// the process exits at once.
+func (a *App) drainOnSignal(timeout time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	a.stopping = make(chan struct{})
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s, finishing in-flight sends (up to %s) before exiting", sig, timeout)
+		close(a.stopping)
+		select {
+		case <-time.After(timeout):
+			log.Printf("Error: shutdown did not finish within SHUTDOWN_TIMEOUT (%s); exiting without saving state", timeout)
+		case sig = <-signals:
+			log.Printf("Received %s again, exiting without saving state", sig)
+		}
+		os.Exit(1)
+	}()
+}
+
+// stopped reports whether a shutdown has been requested.
+func (a *App) stopped() bool {
+	select {
+	case <-a.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, returning false early if a shutdown is requested. A
+// SIGHUP meanwhile reloads --config without cutting the wait short.
+func (a *App) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-a.stopping:
+			return false
+		case <-a.reloads:
+			log.Println("Received SIGHUP, reloading --config")
+			a.reloadPolicy()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsPublishTimeout bounds a single Publish call, including SDK retries.
+const snsPublishTimeout = 30 * time.Second
+
+// snsNotifier publishes each alert to SNS_TOPIC_ARN, for fan-out to SMS,
+// email or Lambda subscribers.
+type snsNotifier struct {
+	client   *sns.Client
+	cfg      *Config
+	topicARN string
+}
+
+// newSNSNotifier loads AWS credentials through the standard chain
+// (environment, shared config, instance role). Without a configured region
+// the topic ARN's region is used.
+func newSNSNotifier(cfg *Config) (*snsNotifier, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = strings.Split(cfg.SNSTopicARN, ":")[3]
+	}
+	return &snsNotifier{client: sns.NewFromConfig(awsCfg), cfg: cfg, topicARN: cfg.SNSTopicARN}, nil
+}
+
+func (n *snsNotifier) Name() string {
+	return "SNS"
+}
+
+// Notify publishes the alert as plain text or, with SNS_MESSAGE_FORMAT=json,
+// as the --emit-ndjson record. The severity is also set as a message
+// attribute so subscriptions can filter on it.
+func (n *snsNotifier) Notify(alert pendingAlert) error {
+	message := alertText(n.cfg, alert, n.cfg.SNSVerbosity)
+	if n.cfg.SNSMessageFormat == "json" {
+		data, err := json.Marshal(newNDJSONRecord(n.cfg, alert))
+		if err != nil {
+			return err
+		}
+		message = string(data)
+	}
+
+	subject := snsSubject(displayProblem(n.cfg, alert.incident.Problem))
+
+	ctx, cancel := context.WithTimeout(context.Background(), snsPublishTimeout)
+	defer cancel()
+	_, err := n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.topicARN),
+		Message:  aws.String(message),
+		Subject:  aws.String(subject),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"severity": {DataType: aws.String("String"), StringValue: aws.String(string(severityOf(n.cfg, alert.incident)))},
+		},
+	})
+	return err
+}
+
+// snsSubjectLimit is SNS's cap on a subject, in characters.
+const snsSubjectLimit = 100
+
+// snsSubject shortens the subject email subscribers see to snsSubjectLimit
+// characters, cutting on a rune boundary since SNS rejects invalid UTF-8.
+func snsSubject(subject string) string {
+	if utf8.RuneCountInString(subject) <= snsSubjectLimit {
+		return subject
+	}
+	return string([]rune(subject)[:snsSubjectLimit])
+}
+
+// validSNSTopicARN checks the shape arn:partition:sns:region:account:topic.
+func validSNSTopicARN(arn string) bool {
+	parts := strings.Split(arn, ":")
+	return len(parts) == 6 && parts[0] == "arn" && parts[2] == "sns" && parts[3] != "" && parts[5] != ""
+}
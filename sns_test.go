@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+func TestSNSSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"short", "MVC PI", "MVC PI"},
+		{"exactly the limit", strings.Repeat("a", 100), strings.Repeat("a", 100)},
+		{"long ascii", strings.Repeat("a", 120), strings.Repeat("a", 100)},
+		{"multibyte at the cut", strings.Repeat("a", 99) + "éé", strings.Repeat("a", 99) + "é"},
+		{"all multibyte", strings.Repeat("🚑", 60), strings.Repeat("🚑", 60)},
+		{"long multibyte", strings.Repeat("🚑", 101), strings.Repeat("🚑", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snsSubject(tt.subject)
+			if got != tt.want {
+				t.Errorf("snsSubject = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("snsSubject = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestSNSNotifierJSONRedacts(t *testing.T) {
+	var messages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, r.PostForm.Get("Message"))
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`))
+	}))
+	defer srv.Close()
+
+	cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}, SNSMessageFormat: "json"})
+	client := sns.New(sns.Options{
+		BaseEndpoint: aws.String(srv.URL),
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   srv.Client(),
+	})
+	n := &snsNotifier{client: client, cfg: cfg, topicARN: "arn:aws:sns:us-east-1:123456789012:alerts"}
+	incident := Incident{Problem: "ASSAULT", Address: "12 Elm St", Timestamp: "2025-09-26 07:01:02.000"}
+	if err := n.Notify(pendingAlert{key: cfg.KeyFor(incident), incident: incident}); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if strings.Contains(messages[0], "12 Elm St") || !strings.Contains(messages[0], `"key":"sha256:`) {
+		t.Errorf("message leaks the address: %s", messages[0])
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore records which incident keys have already been alerted.
+type StateStore interface {
+	// Has reports whether key has already been alerted.
+	Has(key string) (bool, error)
+	// Mark records key as alerted.
+	Mark(key string) error
+	// MarkedAt returns when key was last marked or touched.
+	MarkedAt(key string) (time.Time, bool, error)
+	// Touch records key as alerted now, replacing any earlier time.
+	Touch(key string) error
+	// Prune removes keys marked longer ago than olderThan and reports how many were removed.
+	Prune(olderThan time.Duration) (int, error)
+	// CountOlderThan reports how many keys Prune(olderThan) would remove.
+	CountOlderThan(olderThan time.Duration) (int, error)
+	// NextSequence durably increments and returns the alert sequence number.
+	NextSequence() (int64, error)
+	// Save persists any pending changes.
+	Save() error
+	// Close releases the store's resources.
+	Close() error
+}
+
+// dedupNamespaceRe is what DEDUP_NAMESPACE may contain. It keeps the
+// "namespace/" prefix unambiguous and free of Redis SCAN pattern characters.
+var dedupNamespaceRe = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// newStateStore opens the backend selected by STATE_BACKEND.
+//
+// A DEDUP_NAMESPACE prefixes every key with "namespace/", so runs with
+// different namespaces never see each other's keys, and --prune-state and
+// --since only count their own. A run without one still sees the whole
+// store in those two. STATE_TTL applies to every entry by its own mark
+// time whatever its namespace: a file store compacts other namespaces'
+// expired entries too, and Redis expires each key on its own.
+func newStateStore(cfg *Config) (StateStore, error) {
+	prefix := ""
+	if cfg.DedupNamespace != "" {
+		prefix = cfg.DedupNamespace + "/"
+	}
+	switch cfg.StateBackend {
+	case "file":
+		return newFileStore(cfg.StateFilename, cfg.Timezone, cfg.StateTTL, prefix)
+	case "redis":
+		return newRedisStore(cfg.RedisURL, cfg.StateTTL, prefix)
+	default:
+		return nil, fmt.Errorf("unknown STATE_BACKEND %q", cfg.StateBackend)
+	}
+}
+
+// fileStore keeps sent keys and when they were marked in memory, and writes
+// them to a JSON file on Save. With a TTL, expired keys are dropped on load
+// and before each save.
+//
+// A filename with date placeholders (see expandDateTemplate) is partitioned:
+// every existing partition is loaded, so dedup spans partition boundaries,
+// and each key is saved to the partition for the time it was marked.
+type fileStore struct {
+	filename string
+	loc      *time.Location
+	ttl      time.Duration
+	// prefix is the DEDUP_NAMESPACE part of this store's keys.
+	prefix string
+	sentAt map[string]time.Time
+	dirty  bool
+	// partitions are the partition files loaded or last written.
+	partitions map[string]bool
+}
+
+// newFileStore loads the JSON state file, starting empty if it does not exist.
+func newFileStore(filename string, loc *time.Location, ttl time.Duration, prefix string) (*fileStore, error) {
+	s := &fileStore{filename: filename, loc: loc, ttl: ttl, prefix: prefix, partitions: make(map[string]bool)}
+	if !isDateTemplate(filename) {
+		sentAt, err := loadSentIncidents(filename)
+		if err != nil {
+			return nil, err
+		}
+		s.sentAt = sentAt
+	} else {
+		files, err := filepath.Glob(dateTemplateGlob(filename))
+		if err != nil {
+			return nil, err
+		}
+		s.sentAt = make(map[string]time.Time)
+		for _, file := range files {
+			sentAt, err := loadSentIncidents(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			for key, at := range sentAt {
+				if earlier, ok := s.sentAt[key]; !ok || at.After(earlier) {
+					s.sentAt[key] = at
+				}
+			}
+			s.partitions[file] = true
+		}
+	}
+	if removed := s.compact(); removed > 0 {
+		log.Printf("Compacted %d expired entries from %s", removed, filename)
+	}
+	return s, nil
+}
+
+// compact drops entries older than the TTL in every namespace, except
+// --ignore entries (see permanentEntry). Removing any marks the store dirty,
+// so the next Save writes the file back even if nothing was sent.
+func (s *fileStore) compact() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	return s.prune("", s.ttl)
+}
+
+func (s *fileStore) Has(key string) (bool, error) {
+	_, ok := s.sentAt[s.prefix+key]
+	return ok, nil
+}
+
+func (s *fileStore) Mark(key string) error {
+	s.sentAt[s.prefix+key] = time.Now()
+	s.dirty = true
+	return nil
+}
+
+func (s *fileStore) MarkedAt(key string) (time.Time, bool, error) {
+	at, ok := s.sentAt[s.prefix+key]
+	return at, ok, nil
+}
+
+func (s *fileStore) Touch(key string) error {
+	return s.Mark(key)
+}
+
+func (s *fileStore) Prune(olderThan time.Duration) (int, error) {
+	return s.prune(s.prefix, olderThan), nil
+}
+
+// prune removes the entries under prefix marked longer ago than olderThan,
+// keeping --ignore entries.
+func (s *fileStore) prune(prefix string, olderThan time.Duration) int {
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for key, at := range s.sentAt {
+		if strings.HasPrefix(key, prefix) && at.Before(cutoff) && !permanentEntry(key) {
+			delete(s.sentAt, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.dirty = true
+	}
+	return removed
+}
+
+func (s *fileStore) CountOlderThan(olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	count := 0
+	for key, at := range s.sentAt {
+		if strings.HasPrefix(key, s.prefix) && at.Before(cutoff) && !permanentEntry(key) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Save only rewrites the file when something was marked or compacted since
+// the last save.
+func (s *fileStore) Save() error {
+	s.compact()
+	if !s.dirty {
+		return nil
+	}
+	if isDateTemplate(s.filename) {
+		if err := s.savePartitions(); err != nil {
+			return err
+		}
+	} else if err := saveSentIncidents(s.filename, s.sentAt); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// savePartitions writes each key to the partition for its mark time and
+// removes partitions left with no entries.
+func (s *fileStore) savePartitions() error {
+	byPartition := make(map[string]map[string]time.Time)
+	for key, at := range s.sentAt {
+		file := expandDateTemplate(s.filename, at.In(s.loc))
+		if byPartition[file] == nil {
+			byPartition[file] = make(map[string]time.Time)
+		}
+		byPartition[file][key] = at
+	}
+	for file, sentAt := range byPartition {
+		if err := saveSentIncidents(file, sentAt); err != nil {
+			return err
+		}
+	}
+	for file := range s.partitions {
+		if _, ok := byPartition[file]; ok {
+			continue
+		}
+		for _, name := range []string{file, file + ".sha256", file + ".bak", file + ".bak.sha256"} {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	s.partitions = make(map[string]bool, len(byPartition))
+	for file := range byPartition {
+		s.partitions[file] = true
+	}
+	return nil
+}
+
+// NextSequence keeps the counter in a .seq file next to the state, written
+// straight away so a crash cannot hand the same number out twice.
+func (s *fileStore) NextSequence() (int64, error) {
+	filename := sequenceFilename(s.filename)
+	var seq int64
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		if seq, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return 0, fmt.Errorf("reading %s: %w", filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	seq++
+	if err := os.WriteFile(filename, []byte(strconv.FormatInt(seq, 10)+"\n"), 0644); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// sequenceFilename is the counter file for a state file. Partitioned state
+// shares one counter, named after the template without its placeholders.
+func sequenceFilename(filename string) string {
+	return strings.NewReplacer("%Y", "", "%m", "", "%d", "").Replace(filename) + ".seq"
+}
+
+func (s *fileStore) Close() error {
+	return s.Save()
+}
+
+// loadSentIncidents reads the JSON file of sent alert IDs and when they were
+// sent. Files from older versions store true instead of a time; those entries
+// take the incident's own timestamp from the key, or the load time.
+//
+// A file failing its .sha256 checksum is replaced by the .bak copy written on
+// the previous save; if that is missing or corrupt too, state starts empty.
+func loadSentIncidents(filename string) (map[string]time.Time, error) {
+	sentAt := make(map[string]time.Time)
+	data, err := readVerified(filename)
+	if errors.Is(err, errChecksumMismatch) {
+		log.Printf("Warning: %s failed its checksum, falling back to %s.bak", filename, filename)
+		data, err = readVerified(filename + ".bak")
+		if errors.Is(err, errChecksumMismatch) || os.IsNotExist(err) {
+			log.Printf("Warning: no intact backup of %s, starting with empty state; current incidents may alert again", filename)
+			return sentAt, nil
+		}
+	}
+	if os.IsNotExist(err) {
+		return sentAt, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return sentAt, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for key, value := range raw {
+		var legacy bool
+		if json.Unmarshal(value, &legacy) == nil {
+			if legacy {
+				sentAt[key] = legacySentTime(key)
+			}
+			continue
+		}
+		var at time.Time
+		if err := json.Unmarshal(value, &at); err != nil {
+			return nil, fmt.Errorf("entry %q: %w", key, err)
+		}
+		sentAt[key] = at
+	}
+	return sentAt, nil
+}
+
+// legacySentTime approximates when a pre-timestamp key was sent from the
+// incident timestamp that prefixes it.
+func legacySentTime(key string) time.Time {
+	if len(key) >= len(incidentTimeLayout) {
+		if at, err := time.Parse(incidentTimeLayout, key[:len(incidentTimeLayout)]); err == nil {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// saveSentIncidents writes the updated map of sent alert IDs back to the file,
+// after copying the previous version and its checksum to .bak.
+func saveSentIncidents(filename string, sentAt map[string]time.Time) error {
+	data, err := json.MarshalIndent(sentAt, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := backupStateFile(filename); err != nil {
+		return fmt.Errorf("backing up %s: %w", filename, err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filename+".sha256", []byte(checksum(data)+"\n"), 0644)
+}
+
+// errChecksumMismatch means a state file does not match its .sha256 sidecar.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// checksum is the hex SHA-256 of data, as stored in the .sha256 sidecar.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readVerified reads filename and checks it against filename.sha256. Files
+// without a sidecar, such as those from older versions, are trusted.
+func readVerified(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	want, err := os.ReadFile(filename + ".sha256")
+	if os.IsNotExist(err) {
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(want)) != checksum(data) {
+		return nil, errChecksumMismatch
+	}
+	return data, nil
+}
+
+// backupStateFile copies the current state file and its sidecar to .bak,
+// but only when the file is intact, so a corrupt save never replaces a good backup.
+func backupStateFile(filename string) error {
+	data, err := readVerified(filename)
+	if os.IsNotExist(err) || errors.Is(err, errChecksumMismatch) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename+".bak", data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filename+".bak.sha256", []byte(checksum(data)+"\n"), 0644)
+}
+
+// redisKeyPrefix namespaces this tool's keys inside a shared Redis.
+const redisKeyPrefix = "911-reporting:sent:"
+
+// redisSequenceKey holds the alert counter shared by every instance.
+const redisSequenceKey = "911-reporting:sequence"
+
+// redisStore shares dedup state between instances. Each key is written with
+// SETNX, holds the Unix time it was marked, and expires after the configured
+// TTL; --ignore entries never expire.
+type redisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+	// prefix is redisKeyPrefix plus any DEDUP_NAMESPACE.
+	prefix string
+}
+
+// newRedisStore connects to REDIS_URL and verifies the server is reachable.
+func newRedisStore(redisURL string, ttl time.Duration, prefix string) (*redisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return &redisStore{client: client, ttl: ttl, prefix: redisKeyPrefix + prefix}, nil
+}
+
+func (s *redisStore) Has(key string) (bool, error) {
+	n, err := s.client.Exists(context.Background(), s.prefix+key).Result()
+	return n > 0, err
+}
+
+func (s *redisStore) Mark(key string) error {
+	return s.client.SetNX(context.Background(), s.prefix+key, time.Now().Unix(), s.ttlFor(key)).Err()
+}
+
+// ttlFor is the expiry for key: none for --ignore entries, else STATE_TTL.
+func (s *redisStore) ttlFor(key string) time.Duration {
+	if strings.HasPrefix(key, ignorePrefix) {
+		return 0
+	}
+	return s.ttl
+}
+
+func (s *redisStore) MarkedAt(key string) (time.Time, bool, error) {
+	markedAt, err := s.client.Get(context.Background(), s.prefix+key).Int64()
+	if err == redis.Nil {
+		return time.Time{}, false, nil
+	} else if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(markedAt, 0), true, nil
+}
+
+func (s *redisStore) Touch(key string) error {
+	return s.client.Set(context.Background(), s.prefix+key, time.Now().Unix(), s.ttlFor(key)).Err()
+}
+
+// Prune scans this tool's keys and deletes those marked before the cutoff,
+// keeping --ignore entries.
+func (s *redisStore) Prune(olderThan time.Duration) (int, error) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-olderThan).Unix()
+	removed := 0
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if permanentEntry(strings.TrimPrefix(iter.Val(), redisKeyPrefix)) {
+			continue
+		}
+		markedAt, err := s.client.Get(ctx, iter.Val()).Int64()
+		if err != nil || markedAt >= cutoff {
+			continue
+		}
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, iter.Err()
+}
+
+func (s *redisStore) CountOlderThan(olderThan time.Duration) (int, error) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-olderThan).Unix()
+	count := 0
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if permanentEntry(strings.TrimPrefix(iter.Val(), redisKeyPrefix)) {
+			continue
+		}
+		markedAt, err := s.client.Get(ctx, iter.Val()).Int64()
+		if err == nil && markedAt < cutoff {
+			count++
+		}
+	}
+	return count, iter.Err()
+}
+
+func (s *redisStore) NextSequence() (int64, error) {
+	return s.client.Incr(context.Background(), redisSequenceKey).Result()
+}
+
+// Save is a no-op; every Mark is already durable in Redis.
+func (s *redisStore) Save() error {
+	return nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
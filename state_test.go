@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreKeepsIgnoreEntries(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	tests := []struct {
+		name   string
+		stored string
+		kept   bool
+	}{
+		{"ignore entry", "ignore:2025-09-26 07:01:02.000 5 Oak Ave", true},
+		{"namespaced ignore entry", "north/ignore:2025-09-26 07:01:02.000 5 Oak Ave", true},
+		{"sent key", "2025-09-26 07:01:02.000 5 Oak Ave", false},
+		{"namespaced sent key", "north/2025-09-26 07:01:02.000 5 Oak Ave", false},
+		{"fuzzy key", "fuzzy:mvc pi|100 main st", false},
+	}
+
+	t.Run("compact", func(t *testing.T) {
+		s, err := newFileStore(filepath.Join(t.TempDir(), "state.json"), time.UTC, 168*time.Hour, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			s.sentAt[tt.stored] = old
+		}
+		s.compact()
+		for _, tt := range tests {
+			if _, ok := s.sentAt[tt.stored]; ok != tt.kept {
+				t.Errorf("%s: kept = %v, want %v", tt.name, ok, tt.kept)
+			}
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		s, err := newFileStore(filepath.Join(t.TempDir(), "state.json"), time.UTC, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			s.sentAt[tt.stored] = old
+		}
+		count, err := s.CountOlderThan(time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed, err := s.Prune(time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if removed != 3 || count != removed {
+			t.Errorf("Prune removed %d (counted %d), want 3", removed, count)
+		}
+		for _, tt := range tests {
+			if _, ok := s.sentAt[tt.stored]; ok != tt.kept {
+				t.Errorf("%s: kept = %v, want %v", tt.name, ok, tt.kept)
+			}
+		}
+	})
+
+	t.Run("survives reload", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "state.json")
+		s, err := newFileStore(filename, time.UTC, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.sentAt[ignoreKey("k")] = old
+		s.dirty = true
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+		s, err = newFileStore(filename, time.UTC, time.Hour, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignored, _ := s.Has(ignoreKey("k")); !ignored {
+			t.Error("ignore entry was compacted on load")
+		}
+	})
+}
+
+func TestRedisTTLFor(t *testing.T) {
+	s := &redisStore{ttl: 168 * time.Hour}
+	tests := []struct {
+		key  string
+		want time.Duration
+	}{
+		{ignoreKey("2025-09-26 07:01:02.000 5 Oak Ave"), 0},
+		{"2025-09-26 07:01:02.000 5 Oak Ave", 168 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := s.ttlFor(tt.key); got != tt.want {
+			t.Errorf("ttlFor(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// statePayload is the /state response: the matching incidents from the
+// latest fetch and the alerts archived within the requested window.
+type statePayload struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Active    []Incident      `json:"active"`
+	Since     time.Time       `json:"since"`
+	Alerts    []ArchiveRecord `json:"alerts"`
+}
+
+// handleState serves statePayload as JSON. ?window= takes a duration such
+// as 6h and defaults to 24h. Redacted addresses stay redacted and lose
+// their coordinates, as they would on a map.
+func (m *metrics) handleState(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			http.Error(w, "window must be a positive duration like 6h", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	m.mu.Lock()
+	m.refresh()
+	payload := statePayload{
+		FetchedAt: m.lastSuccess,
+		Active:    make([]Incident, 0, len(m.active)),
+		Since:     time.Now().Add(-window),
+		Alerts:    []ArchiveRecord{},
+	}
+	for _, incident := range m.active {
+		payload.Active = append(payload.Active, redactIncident(cfg, incident))
+	}
+	m.mu.Unlock()
+
+	records, err := readArchiveSince(cfg, payload.Since)
+	if err != nil {
+		log.Printf("Error reading archive for /state: %s", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+	for _, record := range records {
+		record.Key = redactKey(cfg, record.Incident, record.Key)
+		record.Incident = redactIncident(cfg, record.Incident)
+		payload.Alerts = append(payload.Alerts, record)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("Error writing /state: %s", err)
+	}
+}
+
+// redactIncident applies REDACT_ADDRESS_FOR and NO_MAP_FOR to an incident
+// before it leaves the process: a redacted address loses its street number,
+// and an incident hidden from maps loses its coordinates.
+func redactIncident(cfg *Config, incident Incident) Incident {
+	if addressRedacted(cfg, incident) {
+		incident.Address = redactAddress(incident.Address)
+	}
+	if mapHidden(cfg, incident) {
+		incident.Lat, incident.Long = 0, 0
+	}
+	return incident
+}
+
+// redactKey hides the dedup key of an address-redacted incident, since the
+// key usually embeds the address. A hash stays stable for consumers that
+// correlate records without revealing it.
+func redactKey(cfg *Config, incident Incident, key string) string {
+	if !addressRedacted(cfg, incident) {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// requireBasicAuth rejects requests without the HTTP_BASIC_USER and
+// HTTP_BASIC_PASS credentials.
+func requireBasicAuth(cfg *Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.HTTPBasicUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.HTTPBasicPass)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="911-reporting"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// statsBucket holds the counts for one minute of the rolling window.
+type statsBucket struct {
+	fetched int
+	matched int
+}
+
+// incidentCounter keeps per-minute counts of newly observed incidents over a
+// rolling window. Each incident key is only counted the first time it is seen.
+type incidentCounter struct {
+	window  time.Duration
+	buckets map[int64]*statsBucket
+	seen    map[string]time.Time
+}
+
+// newIncidentCounter returns a counter that reports totals over window.
+func newIncidentCounter(window time.Duration) *incidentCounter {
+	return &incidentCounter{
+		window:  window,
+		buckets: make(map[int64]*statsBucket),
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// Observe records an incident from the feed, ignoring keys already counted.
+func (c *incidentCounter) Observe(key string, matched bool, now time.Time) {
+	if _, ok := c.seen[key]; ok {
+		return
+	}
+	c.seen[key] = now
+
+	minute := now.Unix() / 60
+	bucket, ok := c.buckets[minute]
+	if !ok {
+		bucket = &statsBucket{}
+		c.buckets[minute] = bucket
+	}
+	bucket.fetched++
+	if matched {
+		bucket.matched++
+	}
+}
+
+// Totals sums the buckets inside the window and discards anything older.
+func (c *incidentCounter) Totals(now time.Time) (fetched, matched int) {
+	cutoff := now.Add(-c.window).Unix() / 60
+	for minute, bucket := range c.buckets {
+		if minute < cutoff {
+			delete(c.buckets, minute)
+			continue
+		}
+		fetched += bucket.fetched
+		matched += bucket.matched
+	}
+	// Remember keys for a day so long-lived incidents are not counted twice.
+	for key, at := range c.seen {
+		if now.Sub(at) > 24*time.Hour {
+			delete(c.seen, key)
+		}
+	}
+	return fetched, matched
+}
+
+// postStats posts the rolling incident counts to the stats webhook.
+func postStats(client *http.Client, cfg *Config, counter *incidentCounter) {
+	fetched, matched := counter.Totals(time.Now())
+	content := fmt.Sprintf("%d incidents in the last %s (%d matched your filters)",
+		fetched, windowLabel(cfg.StatsInterval), matched)
+
+	if err := postContent(client, cfg.StatsWebhookURL, content); err != nil {
+		log.Printf("Error sending stats: %s", err)
+	}
+}
+
+// windowLabel renders a duration for the stats and digest messages, e.g.
+// "hour", "2 days" or "30m0s".
+func windowLabel(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "hour"
+	case d == 24*time.Hour:
+		return "day"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return d.String()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// tallyDateLayout keys the STATS_FILE days.
+const tallyDateLayout = "2006-01-02"
+
+// tallySeenRetention is how long counted keys are remembered; incidents
+// leave the feed long before this.
+const tallySeenRetention = 7 * 24 * time.Hour
+
+// typeTally is the STATS_FILE contents: matching incidents counted per day
+// (in the incident's zone) and problem type, for trend reporting. Seen
+// holds recently counted keys so each incident is tallied once.
+type typeTally struct {
+	Days map[string]map[string]int `json:"days"`
+	Seen map[string]time.Time      `json:"seen"`
+}
+
+// loadTally reads STATS_FILE, starting empty if it does not exist.
+func loadTally(filename string) (*typeTally, error) {
+	tally := &typeTally{Days: map[string]map[string]int{}, Seen: map[string]time.Time{}}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return tally, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, tally); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if tally.Days == nil {
+		tally.Days = map[string]map[string]int{}
+	}
+	if tally.Seen == nil {
+		tally.Seen = map[string]time.Time{}
+	}
+	return tally, nil
+}
+
+// saveTally forgets old seen keys and writes the tally back.
+func saveTally(filename string, tally *typeTally) error {
+	cutoff := time.Now().Add(-tallySeenRetention)
+	for key, at := range tally.Seen {
+		if at.Before(cutoff) {
+			delete(tally.Seen, key)
+		}
+	}
+	data, err := json.MarshalIndent(tally, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Record counts an incident under its day and problem the first time its
+// key is seen.
+func (t *typeTally) Record(cfg *Config, key string, incident Incident) {
+	if _, ok := t.Seen[key]; ok {
+		return
+	}
+	t.Seen[key] = time.Now()
+	at, err := time.Parse(incidentTimeLayout, incident.Timestamp)
+	if err != nil {
+		at = time.Now()
+	}
+	day := at.In(locationFor(cfg, incident)).Format(tallyDateLayout)
+	if t.Days[day] == nil {
+		t.Days[day] = map[string]int{}
+	}
+	t.Days[day][incident.Problem]++
+}
+
+// parseReportRange reads the --report arguments: an optional start date
+// and an optional end date, defaulting to the last seven days.
+func parseReportRange(args []string, loc *time.Location) (from, to time.Time, err error) {
+	to = time.Now().In(loc)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	from = to.AddDate(0, 0, -6)
+	if len(args) > 2 {
+		return from, to, fmt.Errorf("want at most two dates, got %d", len(args))
+	}
+	if len(args) >= 1 {
+		if from, err = time.ParseInLocation(tallyDateLayout, args[0], loc); err != nil {
+			return from, to, fmt.Errorf("invalid start date %q, want YYYY-MM-DD", args[0])
+		}
+	}
+	if len(args) == 2 {
+		if to, err = time.ParseInLocation(tallyDateLayout, args[1], loc); err != nil {
+			return from, to, fmt.Errorf("invalid end date %q, want YYYY-MM-DD", args[1])
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("end date %s is before start date %s", to.Format(tallyDateLayout), from.Format(tallyDateLayout))
+	}
+	return from, to, nil
+}
+
+// writeReport prints a table with a row per problem type, a column per day
+// from from to to inclusive, and a total, busiest types first.
+func writeReport(w io.Writer, tally *typeTally, from, to time.Time) error {
+	var days []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(tallyDateLayout))
+	}
+	totals := map[string]int{}
+	for _, day := range days {
+		for problem, n := range tally.Days[day] {
+			totals[problem] += n
+		}
+	}
+	if len(totals) == 0 {
+		_, err := fmt.Fprintf(w, "No incidents tallied from %s to %s.\n", days[0], days[len(days)-1])
+		return err
+	}
+	problems := make([]string, 0, len(totals))
+	for problem := range totals {
+		problems = append(problems, problem)
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		if totals[problems[i]] != totals[problems[j]] {
+			return totals[problems[i]] > totals[problems[j]]
+		}
+		return problems[i] < problems[j]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "Problem\t")
+	for _, day := range days {
+		fmt.Fprintf(tw, "%s\t", day)
+	}
+	fmt.Fprint(tw, "Total\t\n")
+	dayTotals := make([]int, len(days))
+	for _, problem := range problems {
+		fmt.Fprintf(tw, "%s\t", problem)
+		for i, day := range days {
+			n := tally.Days[day][problem]
+			dayTotals[i] += n
+			fmt.Fprintf(tw, "%d\t", n)
+		}
+		fmt.Fprintf(tw, "%d\t\n", totals[problem])
+	}
+	fmt.Fprint(tw, "All\t")
+	sum := 0
+	for _, n := range dayTotals {
+		sum += n
+		fmt.Fprintf(tw, "%d\t", n)
+	}
+	fmt.Fprintf(tw, "%d\t\n", sum)
+	return tw.Flush()
+}
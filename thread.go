@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// threadNameLimit is Discord's maximum thread name length.
+const threadNameLimit = 100
+
+// parseThreadNameTemplate parses THREAD_NAME_TEMPLATE, which is rendered
+// against the same fields as GENERIC_WEBHOOK_TEMPLATE, e.g.
+// "{{.Problem}} @ {{.Address}}".
+func parseThreadNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("thread").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := genericTemplateData{Key: "sample", Problem: "MVC PI", Address: "100 Main St", Severity: SeverityOther}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// threadName renders the thread name for an alert, falling back to the
+// problem when the template renders nothing.
+func threadName(cfg *Config, alert pendingAlert) string {
+	data := genericTemplateData{
+		Key:      redactKey(cfg, alert.incident, alert.key),
+		Incident: redactIncident(cfg, alert.incident),
+		Problem:  displayProblem(cfg, alert.incident.Problem),
+		Address:  displayAddress(cfg, alert.incident),
+		Severity: severityOf(cfg, alert.incident),
+		Time:     alert.parsedTime.Format(time.RFC3339),
+	}
+	var b strings.Builder
+	if err := cfg.ThreadNameTemplate.Execute(&b, data); err != nil {
+		log.Printf("Error rendering THREAD_NAME_TEMPLATE for %q: %s", alert.key, err)
+	}
+	name := strings.Join(strings.Fields(b.String()), " ")
+	if name == "" {
+		name = data.Problem
+	}
+	if runes := []rune(name); len(runes) > threadNameLimit {
+		name = string(runes[:threadNameLimit])
+	}
+	return name
+}
+
+// startThread opens a thread on a delivered alert with DISCORD_BOT_TOKEN, so
+// follow-up discussion stays attached to it.
+func startThread(client *http.Client, cfg *Config, msg DiscordMessage, name string) error {
+	if msg.ID == "" || msg.ChannelID == "" {
+		return fmt.Errorf("message location unknown")
+	}
+	payload, err := json.Marshal(map[string]any{"name": name, "auto_archive_duration": 1440})
+	if err != nil {
+		return err
+	}
+	threadURL := fmt.Sprintf("%s/channels/%s/messages/%s/threads", strings.TrimRight(cfg.DiscordAPIURL, "/"), msg.ChannelID, msg.ID)
+	headers := http.Header{"Authorization": {"Bot " + cfg.DiscordBotToken}}
+	_, err = postJSON(client, threadURL, payload, headers)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThreadNameRedacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		problem  string
+		want     string
+	}{
+		{"plain address", "{{.Incident.Address}}", "MVC PI", "12 Elm St"},
+		{"redacted address", "{{.Incident.Address}}", "ASSAULT", "XXX Elm St"},
+		{"redacted display address", "{{.Problem}} @ {{.Address}}", "ASSAULT", "ASSAULT @ XXX Elm St"},
+		{"plain key", "{{.Key}}", "MVC PI", "2025-09-26 07:01:02.000 12 Elm St"},
+		{"empty falls back to the problem", "{{if false}}x{{end}}", "MVC PI", "MVC PI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseThreadNameTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}, ThreadNameTemplate: tmpl})
+			incident := Incident{Problem: tt.problem, Address: "12 Elm St", Timestamp: "2025-09-26 07:01:02.000"}
+			alert := pendingAlert{key: cfg.KeyFor(incident), incident: incident, parsedTime: time.Now()}
+			if got := threadName(cfg, alert); got != tt.want {
+				t.Errorf("threadName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	tmpl, err := parseThreadNameTemplate("{{.Key}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := withDefaultKeys(t, &Config{RedactAddressFor: []string{"ASSAULT"}, ThreadNameTemplate: tmpl})
+	incident := Incident{Problem: "ASSAULT", Address: "12 Elm St", Timestamp: "2025-09-26 07:01:02.000"}
+	if got := threadName(cfg, pendingAlert{key: cfg.KeyFor(incident), incident: incident}); strings.Contains(got, "12 Elm") || !strings.HasPrefix(got, "sha256:") {
+		t.Errorf("threadName for a redacted key = %q, want a sha256: hash", got)
+	}
+}
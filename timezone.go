@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseJurisdictionTimezones parses JURISDICTION_TIMEZONES, a comma-separated
+// list of jurisdiction=zone pairs such as "Raleigh=America/New_York".
+// Jurisdictions are matched case-insensitively.
+func parseJurisdictionTimezones(value string) (map[string]*time.Location, error) {
+	zones := make(map[string]*time.Location)
+	for _, entry := range splitList(value) {
+		jurisdiction, zone, ok := strings.Cut(entry, "=")
+		jurisdiction, zone = strings.TrimSpace(jurisdiction), strings.TrimSpace(zone)
+		if !ok || jurisdiction == "" || zone == "" {
+			return nil, fmt.Errorf("invalid JURISDICTION_TIMEZONES entry %q, want jurisdiction=zone", entry)
+		}
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("JURISDICTION_TIMEZONES entry %q: %w", entry, err)
+		}
+		zones[strings.ToLower(jurisdiction)] = loc
+	}
+	return zones, nil
+}
+
+// locationFor returns the zone an incident's time is displayed in: its
+// jurisdiction's entry in JURISDICTION_TIMEZONES, or TIMEZONE.
+func locationFor(cfg *Config, incident Incident) *time.Location {
+	if loc, ok := cfg.JurisdictionTimezones[strings.ToLower(strings.TrimSpace(incident.Jurisdiction))]; ok {
+		return loc
+	}
+	return cfg.Timezone
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// runID identifies this process in log lines, so one run's activity can be
+// grepped out of a shared log.
+var runID = newTraceID()
+
+// traceID is runID plus the current daemon cycle ID, sent as X-Request-ID on
+// the feed fetch so upstream logs can be matched to ours.
+var traceID = runID
+
+// newTraceID returns a short random hex ID.
+func newTraceID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setLogTrace prefixes every following log line with the run ID and, when
+// cycleID is non-empty, the cycle ID.
+func setLogTrace(cycleID string) {
+	traceID = runID
+	prefix := "run=" + runID + " "
+	if cycleID != "" {
+		traceID += "-" + cycleID
+		prefix += "cycle=" + cycleID + " "
+	}
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	log.SetPrefix(prefix)
+}
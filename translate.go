@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadProblemTranslations reads a JSON object mapping raw CAD problem codes to
+// human-friendly descriptions. Keys are matched case-insensitively.
+func loadProblemTranslations(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	translations := make(map[string]string, len(raw))
+	for code, description := range raw {
+		translations[normalizeProblem(code)] = description
+	}
+	return translations, nil
+}
+
+// displayProblem returns the problem text to show in embeds. The raw problem is
+// left untouched on the incident so matching and dedup keep using it.
+func displayProblem(cfg *Config, problem string) string {
+	description, ok := cfg.ProblemTranslations[normalizeProblem(problem)]
+	if !ok {
+		return problem
+	}
+	if cfg.TranslationMode == "augment" {
+		return fmt.Sprintf("%s (%s)", description, problem)
+	}
+	return description
+}
+
+// normalizeProblem folds a problem string for translation lookups.
+func normalizeProblem(problem string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(problem), " "))
+}
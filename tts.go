@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ttsCacheTTL is how long generated audio is reused, long enough to cover
+// an alert's delivery retries.
+const ttsCacheTTL = time.Hour
+
+// ttsClient requests spoken readouts of incidents from TTS_URL. The endpoint
+// receives {"text": "..."} and answers {"url": "<audio url>"}. Results are
+// cached by incident key so retries and multi-route sends reuse the audio.
+type ttsClient struct {
+	client   *http.Client
+	endpoint string
+	cache    map[string]cachedAudio
+}
+
+// cachedAudio is one generated readout.
+type cachedAudio struct {
+	url string
+	at  time.Time
+}
+
+// newTTSClient returns a client for the configured TTS endpoint.
+func newTTSClient(client *http.Client, endpoint string) *ttsClient {
+	return &ttsClient{client: client, endpoint: endpoint, cache: make(map[string]cachedAudio)}
+}
+
+// AudioURL returns the audio link for an incident readout, generating it on first use.
+func (t *ttsClient) AudioURL(key, text string) (string, error) {
+	if cached, ok := t.cache[key]; ok && time.Since(cached.at) < ttsCacheTTL {
+		return cached.url, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("TTS endpoint returned non-2xx status: %s", resp.Status)
+	}
+
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding TTS response: %w", err)
+	}
+	if result.URL == "" {
+		return "", fmt.Errorf("TTS response has no url")
+	}
+	t.pruneCache()
+	t.cache[key] = cachedAudio{url: result.URL, at: time.Now()}
+	return result.URL, nil
+}
+
+// pruneCache drops readouts older than ttsCacheTTL, so a daemon's cache
+// does not grow without bound.
+func (t *ttsClient) pruneCache() {
+	for key, cached := range t.cache {
+		if time.Since(cached.at) >= ttsCacheTTL {
+			delete(t.cache, key)
+		}
+	}
+}
+
+// ttsText is the sentence read out for an incident, e.g. "MVC PI at 100 Main St".
+func ttsText(cfg *Config, incident Incident) string {
+	return fmt.Sprintf("%s at %s", displayProblem(cfg, incident.Problem), displayAddress(cfg, incident))
+}
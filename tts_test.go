@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTTSCacheExpires(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"url":"https://audio.example/1.mp3"}`))
+	}))
+	defer srv.Close()
+
+	c := newTTSClient(srv.Client(), srv.URL)
+	c.cache["stale"] = cachedAudio{url: "https://audio.example/0.mp3", at: time.Now().Add(-2 * ttsCacheTTL)}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		requests int32
+	}{
+		{"first readout", 0, 1},
+		{"cached", 0, 1},
+		{"expired", 2 * ttsCacheTTL, 2},
+	}
+	for _, tt := range tests {
+		for key, cached := range c.cache {
+			cached.at = cached.at.Add(-tt.age)
+			c.cache[key] = cached
+		}
+		audioURL, err := c.AudioURL("k", "MVC PI at 100 Main St")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if audioURL != "https://audio.example/1.mp3" {
+			t.Errorf("%s: url = %q", tt.name, audioURL)
+		}
+		if got := requests.Load(); got != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.requests)
+		}
+	}
+	if _, ok := c.cache["stale"]; ok || len(c.cache) != 1 {
+		t.Errorf("cache = %v, want only the fresh readout", c.cache)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// trackedIncident is what update detection remembers about an alerted incident.
+type trackedIncident struct {
+	Problem string    `json:"problem"`
+	SentAt  time.Time `json:"sent_at"`
+	// MessageID, ChannelID and GuildID locate the first Discord message for
+	// the incident; any may be empty if it was not captured.
+	MessageID string `json:"message_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	GuildID   string `json:"guild_id,omitempty"`
+	// Sequence is the alert number shown in the first alert's footer.
+	Sequence int64 `json:"sequence,omitempty"`
+}
+
+// messageLink returns a Discord link to the original alert, or "" if the
+// message, channel or server is unknown. DISCORD_GUILD_ID fills in the
+// server when the webhook response did not include it.
+func (t *trackedIncident) messageLink(guildID string) string {
+	if t.GuildID != "" {
+		guildID = t.GuildID
+	}
+	if t.MessageID == "" || t.ChannelID == "" || guildID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, t.ChannelID, t.MessageID)
+}
+
+// loadTrackedIncidents reads the tracked incidents, starting empty if the file does not exist.
+func loadTrackedIncidents(filename string) (map[string]*trackedIncident, error) {
+	tracked := make(map[string]*trackedIncident)
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return tracked, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return tracked, nil
+	}
+	err = json.Unmarshal(data, &tracked)
+	return tracked, err
+}
+
+// saveTrackedIncidents writes the tracked incidents back to the file.
+func saveTrackedIncidents(filename string, tracked map[string]*trackedIncident) error {
+	data, err := json.MarshalIndent(tracked, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// sendUpdate posts a follow-up for an incident whose problem changed since it
+// was alerted. Only once it reaches a destination is the new problem
+// remembered; until then the change is detected, and retried, every cycle.
+func (a *App) sendUpdate(alert pendingAlert) error {
+	log.Printf("Found update to %q: %s is now %s.", alert.key, alert.previousProblem, alert.incident.Problem)
+	if !a.ndjsonOnly {
+		destinations := destinationsFor(a.cfg, alert.incident)
+		delivered := len(destinations) == 0
+		for _, webhookURL := range destinations {
+			if _, err := a.deliver(webhookURL, alert); err == nil {
+				delivered = true
+			}
+		}
+		if !delivered {
+			return fmt.Errorf("update to %q was not delivered anywhere", alert.key)
+		}
+	}
+	a.tracked[alert.key].Problem = alert.incident.Problem
+	return nil
+}
+
+// saveTracked forgets incidents that have left the feed, since they can no
+// longer change, and writes the rest back.
+func (a *App) saveTracked(seen map[string]bool) {
+	for key := range a.tracked {
+		if !seen[key] {
+			delete(a.tracked, key)
+		}
+	}
+	if err := saveTrackedIncidents(a.cfg.UpdateStateFilename, a.tracked); err != nil {
+		log.Printf("Error saving update state: %s", err)
+	}
+}
+
+// problemChanged reports whether a problem differs from the one already
+// alerted by more than threshold, the Levenshtein distance between the
+// normalized texts divided by the longer one's length. Punctuation and case
+// are ignored, so "MVC-INJURY" and "mvc injury" are the same.
+func problemChanged(previous, current string, threshold float64) bool {
+	a, b := []rune(foldProblemText(previous)), []rune(foldProblemText(current))
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return false
+	}
+	return float64(levenshtein(a, b))/float64(longest) > threshold
+}
+
+// foldProblemText upper-cases a problem and reduces punctuation runs to single spaces.
+func foldProblemText(problem string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToUpper(problem), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// levenshtein is the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
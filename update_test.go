@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProblemChanged(t *testing.T) {
+	tests := []struct {
+		previous, current string
+		threshold         float64
+		want              bool
+	}{
+		{"MVC-INJURY", "mvc injury", 0.2, false},
+		{"MVC INJURY", "MVC INJURIES", 0.3, false},
+		{"MVC INJURY", "MVC WITH INJURIES", 0.2, true},
+		{"MVC INJURY", "MVC WITH INJURIES", 0.5, false},
+		{"MVC DAMAGE", "STRUCTURE FIRE", 0.2, true},
+		{"", "", 0.2, false},
+		{"", "MVC", 0.2, true},
+	}
+	for _, tt := range tests {
+		if got := problemChanged(tt.previous, tt.current, tt.threshold); got != tt.want {
+			t.Errorf("problemChanged(%q, %q, %v) = %v, want %v", tt.previous, tt.current, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestSendUpdateOnlyAdvancesAfterDelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantErr   bool
+		wantTrack string
+	}{
+		{"delivered", http.StatusOK, false, "MVC PI"},
+		{"server error", http.StatusInternalServerError, true, "MVC DAMAGE"},
+		{"rejected", http.StatusBadRequest, true, "MVC DAMAGE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"id": "2", "channel_id": "9"}`))
+			}))
+			defer hook.Close()
+
+			a := &App{
+				cfg:     &Config{WebhookURL: hook.URL, Timezone: time.UTC},
+				client:  hook.Client(),
+				tracked: map[string]*trackedIncident{"k": {Problem: "MVC DAMAGE", MessageID: "1", ChannelID: "9"}},
+				errs:    make(errorSummary),
+			}
+			alert := newPendingAlert(a.cfg, "k", Incident{Problem: "MVC PI", Address: "100 Main St", Timestamp: "2025-09-26 08:01:02.000"})
+			alert.previousProblem = "MVC DAMAGE"
+
+			err := a.sendUpdate(alert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendUpdate error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := a.tracked["k"].Problem; got != tt.wantTrack {
+				t.Errorf("tracked problem = %q, want %q", got, tt.wantTrack)
+			}
+			if tt.wantErr && a.errs[errSend] != 1 {
+				t.Errorf("send failures counted = %d, want 1", a.errs[errSend])
+			}
+		})
+	}
+}
+
+func TestMessageLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracked trackedIncident
+		guildID string
+		want    string
+	}{
+		{"from response", trackedIncident{MessageID: "1", ChannelID: "9", GuildID: "5"}, "", "https://discord.com/channels/5/9/1"},
+		{"from DISCORD_GUILD_ID", trackedIncident{MessageID: "1", ChannelID: "9"}, "7", "https://discord.com/channels/7/9/1"},
+		{"response guild wins", trackedIncident{MessageID: "1", ChannelID: "9", GuildID: "5"}, "7", "https://discord.com/channels/5/9/1"},
+		{"no guild", trackedIncident{MessageID: "1", ChannelID: "9"}, "", ""},
+		{"no message", trackedIncident{ChannelID: "9", GuildID: "5"}, "", ""},
+		{"no channel", trackedIncident{MessageID: "1", GuildID: "5"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tracked.messageLink(tt.guildID); got != tt.want {
+				t.Errorf("messageLink = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetriedUpdateKeepsLinkAndHistory(t *testing.T) {
+	var bodies []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"id": "2", "channel_id": "9"}`))
+	}))
+	defer hook.Close()
+
+	archive := filepath.Join(t.TempDir(), "archive.jsonl")
+	a := &App{
+		cfg:     &Config{WebhookURL: hook.URL, Timezone: time.UTC, ArchiveFilename: archive},
+		client:  hook.Client(),
+		tracked: map[string]*trackedIncident{"k": {Problem: "MVC DAMAGE", MessageID: "1", ChannelID: "9", GuildID: "5"}},
+		errs:    make(errorSummary),
+	}
+	// Each cycle rebuilds the update from the tracked incident.
+	for cycle := 1; cycle <= 2; cycle++ {
+		previous := a.tracked["k"]
+		if !problemChanged(previous.Problem, "MVC PI", 0) {
+			t.Fatalf("cycle %d: update no longer detected", cycle)
+		}
+		alert := newPendingAlert(a.cfg, "k", Incident{Problem: "MVC PI", Address: "100 Main St", Timestamp: "2025-09-26 08:01:02.000"})
+		alert.previousProblem = previous.Problem
+		alert.originalURL = previous.messageLink("")
+		err := a.sendUpdate(alert)
+		if (err != nil) != (cycle == 1) {
+			t.Fatalf("cycle %d: sendUpdate error = %v", cycle, err)
+		}
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("posted %d times, want 2", len(bodies))
+	}
+	if !strings.Contains(bodies[1], "https://discord.com/channels/5/9/1") {
+		t.Errorf("retried update lost the original link: %s", bodies[1])
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("archive has %d records, want only the delivered update", lines)
+	}
+}
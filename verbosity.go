@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Verbosity is how much detail a notifier renders, set per notifier with
+// DISCORD_VERBOSITY, MATRIX_VERBOSITY, SNS_VERBOSITY and
+// GENERIC_WEBHOOK_VERBOSITY:
+//
+//   - terse: the address and any warnings about the data, for phones.
+//   - normal (default): EMBED_FIELDS, enrichment and notes, as before.
+//   - verbose: normal plus every other field and the incident's id and units.
+type Verbosity string
+
+const (
+	VerbosityTerse   Verbosity = "terse"
+	VerbosityNormal  Verbosity = "normal"
+	VerbosityVerbose Verbosity = "verbose"
+)
+
+// verboseFieldOrder is the order verbose output adds the EMBED_FIELDS
+// entries that were not configured.
+var verboseFieldOrder = []string{"problem", "jurisdiction", "address", "time", "detected", "coordinates"}
+
+// envVerbosity parses a verbosity from the named environment variable.
+func envVerbosity(name string) (Verbosity, error) {
+	v := Verbosity(strings.ToLower(envOrDefault(name, string(VerbosityNormal))))
+	if v != VerbosityTerse && v != VerbosityNormal && v != VerbosityVerbose {
+		return "", fmt.Errorf("%s must be terse, normal or verbose, got %q", name, os.Getenv(name))
+	}
+	return v, nil
+}
+
+// embedFieldNames lists the EMBED_FIELDS entries rendered at a verbosity.
+func embedFieldNames(cfg *Config, verbosity Verbosity) []string {
+	switch verbosity {
+	case VerbosityTerse:
+		return []string{"address"}
+	case VerbosityVerbose:
+		names := slices.Clone(cfg.EmbedFields)
+		for _, name := range verboseFieldOrder {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	default:
+		return cfg.EmbedFields
+	}
+}
+
+// alertNotes are the fields after the EMBED_FIELDS ones: enrichment and
+// notes such as modifiers and amendments unless terse, the id and units
+// when verbose, and at every verbosity the warnings that the time or
+// location may be wrong.
+func alertNotes(cfg *Config, alert pendingAlert, verbosity Verbosity) []EmbedField {
+	incident := alert.incident
+	var fields []EmbedField
+	if verbosity != VerbosityTerse {
+		fields = append(fields, alert.extraFields...)
+	}
+	if verbosity == VerbosityVerbose {
+		if incident.ID != "" {
+			fields = append(fields, EmbedField{Name: "ID", Value: incident.ID})
+		}
+		if len(incident.Units) > 0 {
+			fields = append(fields, EmbedField{Name: "Units", Value: strings.Join(incident.Units, ", ")})
+		}
+	}
+	if verbosity != VerbosityTerse {
+		if len(incident.Modifiers) > 0 {
+			fields = append(fields, EmbedField{Name: "Modifiers", Value: "`" + strings.Join(incident.Modifiers, "` `") + "`"})
+		}
+		if alert.amendedFrom != "" {
+			fields = append(fields, EmbedField{Name: "Amended", Value: "Originally reported as " + displayProblem(cfg, alert.amendedFrom)})
+		}
+	}
+	if alert.futureTime {
+		fields = append(fields, EmbedField{Name: "Time", Value: "⚠️ Reported in the future; the feed's clock may be off"})
+	}
+	// Coarse coordinates would put a map marker somewhere misleading, so a
+	// flagged incident says so instead of showing a map.
+	if coordsCoarse(cfg, incident) {
+		fields = append(fields, EmbedField{Name: "Location", Value: "Approximate (coordinates are rounded)"})
+	}
+	return fields
+}
+
+// summaryFields are the EMBED_FIELDS entries alertSummary already states.
+var summaryFields = []string{"problem", "address", "jurisdiction", "time"}
+
+// alertText renders an alert as plain text for SNS and Matrix: the problem
+// and address when terse, alertSummary when normal, and alertSummary plus a
+// line per other field when verbose.
+func alertText(cfg *Config, alert pendingAlert, verbosity Verbosity) string {
+	switch verbosity {
+	case VerbosityTerse:
+		return displayProblem(cfg, alert.incident.Problem) + " at " + displayAddress(cfg, alert.incident)
+	case VerbosityVerbose:
+		lines := []string{alertSummary(cfg, alert)}
+		for _, field := range detailFields(cfg, alert) {
+			lines = append(lines, field.Name+": "+field.Value)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return alertSummary(cfg, alert)
+	}
+}
+
+// detailFields are the verbose fields beyond what alertSummary states.
+func detailFields(cfg *Config, alert pendingAlert) []EmbedField {
+	var fields []EmbedField
+	for _, name := range embedFieldNames(cfg, VerbosityVerbose) {
+		if !slices.Contains(summaryFields, name) {
+			fields = append(fields, embedFieldBuilders[name](cfg, alert))
+		}
+	}
+	return append(fields, alertNotes(cfg, alert, VerbosityVerbose)...)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbedFieldNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		fields    []string
+		verbosity Verbosity
+		want      []string
+	}{
+		{"terse", []string{"address", "jurisdiction"}, VerbosityTerse, []string{"address"}},
+		{"normal", []string{"address", "jurisdiction"}, VerbosityNormal, []string{"address", "jurisdiction"}},
+		{"verbose", []string{"address", "jurisdiction"}, VerbosityVerbose, []string{"address", "jurisdiction", "problem", "time", "detected", "coordinates"}},
+		{"verbose keeps configured order", []string{"coordinates", "problem"}, VerbosityVerbose, []string{"coordinates", "problem", "jurisdiction", "address", "time", "detected"}},
+	}
+	for _, tt := range tests {
+		cfg := &Config{EmbedFields: tt.fields}
+		if got := embedFieldNames(cfg, tt.verbosity); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: embedFieldNames = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.verbosity == VerbosityVerbose && !slices.Equal(cfg.EmbedFields, tt.fields) {
+			t.Errorf("%s: EMBED_FIELDS was modified to %v", tt.name, cfg.EmbedFields)
+		}
+	}
+}
+
+func TestAlertNotes(t *testing.T) {
+	alert := pendingAlert{
+		incident:    Incident{ID: "42", Units: []string{"E5", "M12"}, Modifiers: []string{"INJURY"}, Lat: 35.78, Long: -78.64},
+		amendedFrom: "MVC",
+		futureTime:  true,
+		extraFields: []EmbedField{{Name: "Weather", Value: "Rain"}},
+	}
+	cfg := &Config{MinCoordPrecision: 4}
+	tests := []struct {
+		verbosity Verbosity
+		want      []string
+	}{
+		{VerbosityTerse, []string{"Time", "Location"}},
+		{VerbosityNormal, []string{"Weather", "Modifiers", "Amended", "Time", "Location"}},
+		{VerbosityVerbose, []string{"Weather", "ID", "Units", "Modifiers", "Amended", "Time", "Location"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, field := range alertNotes(cfg, alert, tt.verbosity) {
+			names = append(names, field.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("%s: alertNotes = %v, want %v", tt.verbosity, names, tt.want)
+		}
+	}
+}
+
+func TestAlertText(t *testing.T) {
+	cfg := &Config{EmbedFields: []string{"address"}, CoordDisplayDecimals: 2}
+	alert := pendingAlert{
+		incident:   Incident{Problem: "MVC PI", Address: "100 Main St", Jurisdiction: "Raleigh", ID: "42", Lat: 35.7796, Long: -78.6382},
+		parsedTime: time.Date(2025, 9, 26, 8, 1, 2, 0, time.UTC),
+		firstSeen:  time.Date(2025, 9, 26, 8, 2, 0, 0, time.UTC),
+	}
+	summary := "MVC PI at 100 Main St (Raleigh), Fri Sep 26, 8:01 AM UTC"
+	tests := []struct {
+		verbosity Verbosity
+		want      string
+	}{
+		{VerbosityTerse, "MVC PI at 100 Main St"},
+		{VerbosityNormal, summary},
+		{VerbosityVerbose, summary + "\nDetected: 8:02:00 AM UTC\nCoordinates: 35.78, -78.64\nID: 42"},
+	}
+	for _, tt := range tests {
+		if got := alertText(cfg, alert, tt.verbosity); got != tt.want {
+			t.Errorf("%s: alertText = %q, want %q", tt.verbosity, got, tt.want)
+		}
+	}
+}
+
+func TestVerboseOutputWithholdsHiddenCoordinates(t *testing.T) {
+	cfg := &Config{RedactAddressFor: []string{"ASSAULT"}, NoMapFor: []string{"WELFARE"}, CoordDisplayDecimals: 4}
+	tests := []struct {
+		problem  string
+		withheld bool
+	}{
+		{"MVC PI", false},
+		{"ASSAULT", true},
+		{"WELFARE CHECK", true},
+	}
+	for _, tt := range tests {
+		alert := pendingAlert{
+			incident:   Incident{Problem: tt.problem, Address: "100 Main St", Lat: 35.7796, Long: -78.6382},
+			parsedTime: time.Date(2025, 9, 26, 8, 1, 2, 0, time.UTC),
+		}
+		text := alertText(cfg, alert, VerbosityVerbose)
+		if leaked := strings.Contains(text, "35.7796"); leaked == tt.withheld {
+			t.Errorf("%s: coordinates in verbose text = %v, want %v:\n%s", tt.problem, leaked, !tt.withheld, text)
+		}
+		for _, field := range buildEmbedFields(cfg, alert, VerbosityVerbose) {
+			if field.Name == "Coordinates" && (field.Value == "Withheld") != tt.withheld {
+				t.Errorf("%s: Coordinates field = %q", tt.problem, field.Value)
+			}
+		}
+	}
+}
+
+func TestEnvVerbosity(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Verbosity
+		wantErr bool
+	}{
+		{"", VerbosityNormal, false},
+		{"TERSE", VerbosityTerse, false},
+		{"verbose", VerbosityVerbose, false},
+		{"loud", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DISCORD_VERBOSITY", tt.value)
+		got, err := envVerbosity("DISCORD_VERBOSITY")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("envVerbosity(%q) = %q, %v, want %q, wantErr %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// weatherCacheTTL is how long looked-up conditions are reused. Lookups are
+// keyed by the hour, so older entries are rarely asked for again.
+const weatherCacheTTL = time.Hour
+
+// weatherClient looks up current conditions from an OpenWeatherMap-compatible
+// endpoint. Results are cached per ~1km grid cell per hour to limit API calls.
+type weatherClient struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	cache    map[string]cachedConditions
+}
+
+// cachedConditions is one cached lookup.
+type cachedConditions struct {
+	conditions string
+	at         time.Time
+}
+
+// newWeatherClient returns a client for WEATHER_API_URL.
+func newWeatherClient(client *http.Client, endpoint, apiKey string) *weatherClient {
+	return &weatherClient{client: client, endpoint: endpoint, apiKey: apiKey, cache: make(map[string]cachedConditions)}
+}
+
+// Conditions returns a short description such as "72°F, light rain".
+func (w *weatherClient) Conditions(lat, long float64, at time.Time) (string, error) {
+	key := fmt.Sprintf("%.2f,%.2f@%s", lat, long, at.Truncate(time.Hour).Format(time.RFC3339))
+	if cached, ok := w.cache[key]; ok && time.Since(cached.at) < weatherCacheTTL {
+		return cached.conditions, nil
+	}
+
+	u, err := url.Parse(w.endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("lat", fmt.Sprintf("%.4f", lat))
+	q.Set("lon", fmt.Sprintf("%.4f", long))
+	q.Set("units", "imperial")
+	if w.apiKey != "" {
+		q.Set("appid", w.apiKey)
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := w.client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("weather API returned non-2xx status: %s", resp.Status)
+	}
+
+	var result struct {
+		Main struct {
+			Temp float64 `json:"temp"`
+		} `json:"main"`
+		Weather []struct {
+			Description string `json:"description"`
+		} `json:"weather"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding weather response: %w", err)
+	}
+
+	conditions := fmt.Sprintf("%.0f°F", result.Main.Temp)
+	if len(result.Weather) > 0 && result.Weather[0].Description != "" {
+		conditions += ", " + strings.ToLower(result.Weather[0].Description)
+	}
+	w.pruneCache()
+	w.cache[key] = cachedConditions{conditions: conditions, at: time.Now()}
+	return conditions, nil
+}
+
+// pruneCache drops lookups older than weatherCacheTTL, so a daemon's cache
+// does not grow without bound.
+func (w *weatherClient) pruneCache() {
+	for key, cached := range w.cache {
+		if time.Since(cached.at) >= weatherCacheTTL {
+			delete(w.cache, key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWeatherCacheExpires(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"main":{"temp":72.4},"weather":[{"description":"Light Rain"}]}`))
+	}))
+	defer srv.Close()
+
+	w := newWeatherClient(srv.Client(), srv.URL, "")
+	at := time.Date(2025, 9, 26, 8, 1, 2, 0, time.UTC)
+	w.cache["stale"] = cachedConditions{conditions: "50°F", at: time.Now().Add(-2 * weatherCacheTTL)}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		requests int32
+	}{
+		{"first lookup", 0, 1},
+		{"cached", 0, 1},
+		{"expired", 2 * weatherCacheTTL, 2},
+	}
+	for _, tt := range tests {
+		for key, cached := range w.cache {
+			cached.at = cached.at.Add(-tt.age)
+			w.cache[key] = cached
+		}
+		conditions, err := w.Conditions(35.78, -78.64, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conditions != "72°F, light rain" {
+			t.Errorf("%s: conditions = %q", tt.name, conditions)
+		}
+		if got := requests.Load(); got != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.requests)
+		}
+	}
+	if _, ok := w.cache["stale"]; ok || len(w.cache) != 1 {
+		t.Errorf("cache = %v, want only the fresh lookup", w.cache)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// webhookError is a non-2xx webhook response.
+type webhookError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook returned non-2xx status: %s", e.Status)
+}
+
+// Transient reports whether retrying could succeed: rate limits and server
+// errors are transient, any other 4xx means the request itself is bad.
+func (e *webhookError) Transient() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isTransient reports whether err is worth retrying. Network errors are
+// always treated as transient.
+func isTransient(err error) bool {
+	var webhookErr *webhookError
+	if errors.As(err, &webhookErr) {
+		return webhookErr.Transient()
+	}
+	return true
+}
+
+// postJSON makes a single POST with any extra headers and returns the
+// response body, or a *webhookError for non-2xx responses.
+func postJSON(client *http.Client, url string, payload []byte, headers http.Header) ([]byte, error) {
+	return sendJSON(client, http.MethodPost, url, payload, headers)
+}
+
+// sendJSON is postJSON for any method.
+func sendJSON(client *http.Client, method, url string, payload []byte, headers http.Header) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &webhookError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return body, nil
+}
+
+// postWebhook POSTs payload, retrying transient failures up to
+// WEBHOOK_RETRIES times with exponential backoff starting at
+// WEBHOOK_RETRY_BACKOFF. A Retry-After header overrides the backoff.
+// Permanent failures are returned immediately.
+func postWebhook(client *http.Client, cfg *Config, url string, payload []byte, headers http.Header) ([]byte, error) {
+	return sendWithRetry(client, cfg, http.MethodPost, url, payload, headers)
+}
+
+// sendWithRetry is postWebhook for any method; callers using PUT must make
+// the request idempotent.
+func sendWithRetry(client *http.Client, cfg *Config, method, url string, payload []byte, headers http.Header) ([]byte, error) {
+	backoff := cfg.WebhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, err := sendJSON(client, method, url, payload, headers)
+		if err == nil {
+			return body, nil
+		}
+		if !isTransient(err) {
+			return nil, fmt.Errorf("permanent failure, not retrying: %w", err)
+		}
+		if attempt >= cfg.WebhookRetries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		wait := backoff
+		var webhookErr *webhookError
+		if errors.As(err, &webhookErr) && webhookErr.RetryAfter > 0 {
+			wait = webhookErr.RetryAfter
+		}
+		log.Printf("Transient webhook error (%s), retrying in %s", err, wait)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning zero when it is absent or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
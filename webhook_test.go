@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendWithRetryClassification(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		attempts int32
+	}{
+		{"ok", []int{200}, false, 1},
+		{"server error then ok", []int{502, 200}, false, 2},
+		{"rate limited then ok", []int{429, 204}, false, 2},
+		{"server error exhausts retries", []int{500, 500, 500}, true, 3},
+		{"bad request is permanent", []int{400, 200}, true, 1},
+		{"not found is permanent", []int{404, 200}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				status := tt.statuses[min(int(n), len(tt.statuses))-1]
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0.001")
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			cfg := &Config{WebhookRetries: 2, WebhookRetryBackoff: time.Millisecond}
+			_, err := postWebhook(srv.Client(), cfg, srv.URL, []byte(`{}`), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&webhookError{StatusCode: 500}, true},
+		{&webhookError{StatusCode: 503}, true},
+		{&webhookError{StatusCode: 429}, true},
+		{&webhookError{StatusCode: 400}, false},
+		{&webhookError{StatusCode: 401}, false},
+		{&webhookError{StatusCode: 404}, false},
+		{errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"-1", 0},
+		{"soon", 0},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}